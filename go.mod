@@ -6,6 +6,7 @@ require (
 	github.com/boltdb/bolt v1.3.1
 	github.com/spf13/cobra v1.8.1
 	golang.org/x/crypto v0.27.0
+	golang.org/x/term v0.24.0
 )
 
 require (
@@ -5,37 +5,73 @@ import (
 	"encoding/gob"
 	"time"
 
+	common "github.com/blockmandu/pkg/commons"
 	"github.com/blockmandu/pkg/transaction"
 )
 
+// Block carries its header, which is what gets hashed and mined, and the
+// transactions the header's merkle root commits to.
 type Block struct {
-	Transactions  []*transaction.Transaction
-	PrevBlockHash []byte
-	Hash          []byte
-	Timestamp     int64
-	Nonce         int
+	BlockHeader
+	Transactions []*transaction.Transaction
+	Hash         []byte
+	Height       int
 }
 
-func NewBlock(txs []*transaction.Transaction, prevBlockHash []byte) (*Block, error) {
-	block := &Block{Timestamp: time.Now().Unix(), Transactions: txs, PrevBlockHash: prevBlockHash, Hash: []byte{}, Nonce: 0}
-	pow := NewProofOfWork(block)
-	nonce, hash, err := pow.Run()
+func NewBlock(txs []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) (*Block, error) {
+	block, err := newBlockTemplate(txs, prevBlockHash, height, bits, timestamp)
 	if err != nil {
 		return nil, err
 	}
-	block.Nonce, block.Hash = nonce, hash[:]
+
+	return block, block.Mine()
+}
+
+// newBlockTemplate assembles a block without its proof of work, see Mine.
+func newBlockTemplate(txs []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) (*Block, error) {
+	header := BlockHeader{Version: blockVersion, PrevBlockHash: prevBlockHash, Timestamp: timestamp, Bits: bits, Nonce: 0}
+	block := &Block{BlockHeader: header, Transactions: txs, Hash: []byte{}, Height: height}
+
+	if err := block.addWitnessCommitment(); err != nil {
+		return nil, err
+	}
+
+	merkleRoot, err := block.HashTransaction()
+	if err != nil {
+		return nil, err
+	}
+	block.MerkleRoot = merkleRoot
 
 	return block, nil
 }
 
+// Mine searches the nonce giving the block a hash below the target of its
+// bits.
+func (b *Block) Mine() error {
+	nonce, hash, err := NewProofOfWork(b).Run()
+	if err != nil {
+		return err
+	}
+	b.Nonce, b.Hash = nonce, hash
+
+	return nil
+}
+
 func NewGenesisBlock(coinbase *transaction.Transaction) (*Block, error) {
-	return NewBlock([]*transaction.Transaction{coinbase}, []byte{})
+	return NewBlock([]*transaction.Transaction{coinbase}, []byte{}, 0, InitialBits, time.Now().Unix())
 }
 
+// HashTransaction computes the merkle root of the block transactions, over
+// their IDs from witnessBlockVersion on and their encodings before.
 func (b *Block) HashTransaction() ([]byte, error) {
 	var txs [][]byte
 
 	for _, tx := range b.Transactions {
+		if b.Version >= witnessBlockVersion {
+			txs = append(txs, tx.ID)
+			continue
+		}
+
 		serialized, err := tx.Serialize()
 		if err != nil {
 			return nil, err
@@ -47,18 +83,83 @@ func (b *Block) HashTransaction() ([]byte, error) {
 	return NewMerkleTree(txs).RootNode.Data, nil
 }
 
+// Serialize encodes the block canonically:
+//
+//	version      uint32, common.EncodingVersion
+//	header       88 bytes, see BlockHeader.Serialize
+//	height       uint32
+//	transactions uint32 count, then each transaction encoding as bytes
+//
+// The block hash is the header hash and is not encoded.
 func (b *Block) Serialize() ([]byte, error) {
-	var result bytes.Buffer
-	encoder := gob.NewEncoder(&result)
-	err := encoder.Encode(b)
+	e := common.NewEncoder()
+	e.PutRaw(b.BlockHeader.Serialize())
+	e.PutUint32(uint32(b.Height))
+
+	e.PutUint32(uint32(len(b.Transactions)))
+	for _, tx := range b.Transactions {
+		serialized, err := tx.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		e.PutBytes(serialized)
+	}
+
+	return e.Bytes(), nil
+}
+
+// DeserializeBlock decodes a canonical block of canonical transactions, as
+// received from other nodes.
+func DeserializeBlock(b []byte) (*Block, error) {
+	if common.IsLegacyEncoding(b) {
+		return nil, common.ErrLegacyEncoding
+	}
+
+	return decodeBlock(b, transaction.DeserializeTransaction)
+}
+
+// deserializeStoredBlock decodes a block of the database, which may be
+// stored with gob, or hold transactions stored with gob.
+func deserializeStoredBlock(b []byte) (*Block, error) {
+	if common.IsLegacyEncoding(b) {
+		return deserializeLegacyBlock(b)
+	}
+
+	return decodeBlock(b, transaction.DeserializeStoredTransaction)
+}
+
+func decodeBlock(b []byte, deserializeTx func([]byte) (transaction.Transaction, error)) (*Block, error) {
+	d := common.NewDecoder(b)
+
+	header, err := DeserializeBlockHeader(d.Raw(headerLength))
 	if err != nil {
 		return nil, err
 	}
 
-	return result.Bytes(), nil
+	block := &Block{BlockHeader: *header, Height: int(d.Uint32())}
+
+	// A transaction is at least its length and an empty encoding.
+	block.Transactions = make([]*transaction.Transaction, d.Count(4+16))
+	for i := range block.Transactions {
+		tx, err := deserializeTx(d.Bytes())
+		if err != nil {
+			if derr := d.Finish(); derr != nil {
+				return nil, derr
+			}
+			return nil, err
+		}
+		block.Transactions[i] = &tx
+	}
+
+	if err = d.Finish(); err != nil {
+		return nil, err
+	}
+
+	block.Hash = block.BlockHeader.Hash()
+	return block, nil
 }
 
-func DeserializeBlock(b []byte) (*Block, error) {
+func deserializeLegacyBlock(b []byte) (*Block, error) {
 	var block Block
 	decoder := gob.NewDecoder(bytes.NewReader(b))
 	err := decoder.Decode(&block)
@@ -66,5 +167,14 @@ func DeserializeBlock(b []byte) (*Block, error) {
 		return nil, err
 	}
 
+	var first struct{ Transactions []transaction.FirstVersionTx }
+	if err = gob.NewDecoder(bytes.NewReader(b)).Decode(&first); err != nil {
+		return nil, err
+	}
+	for i, tx := range block.Transactions {
+		first.Transactions[i].Upgrade(tx)
+	}
+
+	transaction.MarkLegacy(block.Transactions)
 	return &block, nil
 }
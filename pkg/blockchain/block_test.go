@@ -0,0 +1,94 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+func TestBlockRoundTrip(t *testing.T) {
+	bc, w := newTestChain(t)
+	_, to := newTestWallet(t)
+	block := mineTestBlock(t, bc, to, newTestPayment(t, bc, w, to, 3, 1))
+
+	data, err := block.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DeserializeBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Hash, block.Hash) || got.Height != block.Height || len(got.Transactions) != len(block.Transactions) {
+		t.Fatalf("DeserializeBlock = block %x at height %d, want %x at height %d", got.Hash, got.Height, block.Hash, block.Height)
+	}
+	for i, tx := range got.Transactions {
+		if !bytes.Equal(tx.ID, block.Transactions[i].ID) {
+			t.Errorf("transaction %d is %x, want %x", i, tx.ID, block.Transactions[i].ID)
+		}
+	}
+
+	again, err := got.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Error("encoding changed after a round trip")
+	}
+}
+
+func TestDeserializeMalformedBlock(t *testing.T) {
+	bc, _ := newTestChain(t)
+	_, to := newTestWallet(t)
+
+	data, err := mineTestBlock(t, bc, to).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: ErrBadHeader},
+		{name: "truncated header", data: data[:40], err: ErrBadHeader},
+		{name: "truncated transaction", data: data[:len(data)-1], err: common.ErrTruncated},
+		{name: "trailing byte", data: append(append([]byte{}, data...), 0), err: common.ErrTrailingData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DeserializeBlock(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("DeserializeBlock = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeserializeGobBlock(t *testing.T) {
+	_, address := newTestWallet(t)
+	data := gobEncode(t, firstVersionBlock{
+		Transactions: []*firstVersionTx{{
+			ID:   []byte{1},
+			Vin:  []firstVersionInput{{Txid: []byte{}, Vout: -1, PubKey: []byte("reward")}},
+			Vout: []firstVersionOutput{{Value: 10, PubKeyHash: testPubKeyHash(t, address)}},
+		}},
+		Hash: []byte("stored hash"),
+	})
+
+	if _, err := DeserializeBlock(data); !errors.Is(err, common.ErrLegacyEncoding) {
+		t.Errorf("DeserializeBlock = %v, want %v", err, common.ErrLegacyEncoding)
+	}
+
+	block, err := deserializeStoredBlock(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Transactions) != 1 || !bytes.Equal(block.Transactions[0].ID, []byte{1}) {
+		t.Errorf("stored block decoded with transactions %+v", block.Transactions)
+	}
+}
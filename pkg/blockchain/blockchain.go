@@ -8,8 +8,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
-	common "github.com/blockmandu/pkg/commons"
 	"github.com/blockmandu/pkg/transaction"
 	"github.com/blockmandu/pkg/wallet"
 	"github.com/boltdb/bolt"
@@ -17,29 +17,47 @@ import (
 
 const (
 	dbFile              = "./resources/blockchain.db"
+	nodeDBFile          = "./resources/blockchain_%s.db"
 	blocksBucket        = "blocks"
 	utxoBucket          = "chainstate"
 	genesisCoinbaseData = "The first ever coinbase trainsaction on Blockmandu"
 )
 
+var (
+	ErrBlockNotFound = errors.New("block is not found")
+	ErrOrphanBlock   = errors.New("parent block is not found")
+	ErrBadHeight     = errors.New("block height does not follow its parent")
+)
+
 type Blockchain struct {
 	DB  *bolt.DB
 	tip []byte
 }
 
-func dbExists() bool {
-	_, err := os.Stat(dbFile)
+// dbPath returns the database file of the given node, an empty nodeID keeps
+// the single-node default.
+func dbPath(nodeID string) string {
+	if nodeID == "" {
+		return dbFile
+	}
+
+	return fmt.Sprintf(nodeDBFile, nodeID)
+}
+
+func dbExists(path string) bool {
+	_, err := os.Stat(path)
 	return !os.IsNotExist(err)
 }
 
-func NewBlockchain(address string) (*Blockchain, error) {
-	if !dbExists() {
+func NewBlockchain(nodeID string) (*Blockchain, error) {
+	path := dbPath(nodeID)
+	if !dbExists(path) {
 		fmt.Println("No existing blockchain found. Create one first.")
 		os.Exit(1)
 	}
 
 	var tip []byte
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +66,21 @@ func NewBlockchain(address string) (*Blockchain, error) {
 		b := tx.Bucket([]byte(blocksBucket))
 		tip = b.Get([]byte("l"))
 
-		return nil
+		if err := dropLegacyMempool(tx); err != nil {
+			return err
+		}
+
+		indexed := tx.Bucket([]byte(blockIndexBucket)) != nil
+		if err := ensureBlockIndex(tx); err != nil {
+			return err
+		}
+		if indexed {
+			return nil
+		}
+
+		// The first versions stored unspent outputs without their index in
+		// the transaction, and no undo data.
+		return rebuildChainstate(tx)
 	})
 
 	if err != nil {
@@ -60,19 +92,20 @@ func NewBlockchain(address string) (*Blockchain, error) {
 	return &bc, nil
 }
 
-func CreateBlockchain(address string) (*Blockchain, error) {
-	if dbExists() {
+func CreateBlockchain(address, nodeID string) (*Blockchain, error) {
+	path := dbPath(nodeID)
+	if dbExists(path) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
 	}
 
 	var tip []byte
-	db, err := bolt.Open(dbFile, 0600, nil)
+	db, err := bolt.Open(path, 0600, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	cbtx, err := transaction.NewCoinbaseTX(address, genesisCoinbaseData)
+	cbtx, err := transaction.NewCoinbaseTX(address, genesisCoinbaseData, 0, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -103,6 +136,11 @@ func CreateBlockchain(address string) (*Blockchain, error) {
 			return err
 		}
 
+		_, err = indexBlock(tx, genesisBlock)
+		if err != nil {
+			return err
+		}
+
 		tip = genesisBlock.Hash
 		return nil
 	})
@@ -113,51 +151,6 @@ func CreateBlockchain(address string) (*Blockchain, error) {
 	return &Blockchain{tip: tip, DB: db}, nil
 }
 
-func (bc *Blockchain) FindUnspentTransactions(pubKeyHash []byte) []transaction.Transaction {
-	var unspentTXs []transaction.Transaction
-	spentTXOs := make(map[string][]int)
-	bci := bc.Iterator()
-
-	for {
-		block := bci.Next()
-
-		for _, tx := range block.Transactions {
-			txID := hex.EncodeToString(tx.ID)
-
-		Outputs:
-			for outIdx, out := range tx.Vout {
-				// Was the output spent?
-				if spentTXOs[txID] != nil {
-					for _, spentOutIdx := range spentTXOs[txID] {
-						if spentOutIdx == outIdx {
-							continue Outputs
-						}
-					}
-				}
-
-				if out.IsLockedWithKey(pubKeyHash) {
-					unspentTXs = append(unspentTXs, *tx)
-				}
-			}
-
-			if !tx.IsCoinbase() {
-				for _, in := range tx.Vin {
-					if in.UsesKey(pubKeyHash) {
-						inTxID := hex.EncodeToString(in.Txid)
-						spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
-					}
-				}
-			}
-		}
-
-		if len(block.PrevBlockHash) == 0 {
-			break
-		}
-	}
-
-	return unspentTXs
-}
-
 func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 	UTXO := make(map[string]transaction.TXOutputs)
 	spentTXOs := make(map[string][]int)
@@ -171,6 +164,10 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 
 		Outputs:
 			for outIdx, out := range tx.Vout {
+				if out.IsUnspendable() {
+					continue
+				}
+
 				if spentTXOs[txID] != nil {
 					for _, spentOutIdx := range spentTXOs[txID] {
 						if spentOutIdx == outIdx {
@@ -179,8 +176,11 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 					}
 				}
 
-				outs := UTXO[txID]
-				outs.Outputs = append(outs.Outputs, out)
+				outs, ok := UTXO[txID]
+				if !ok {
+					outs = transaction.NewTXOutputs()
+				}
+				outs.Outputs[outIdx] = out
 				UTXO[txID] = outs
 			}
 
@@ -200,32 +200,31 @@ func (bc *Blockchain) FindUTXO() map[string]transaction.TXOutputs {
 	return UTXO
 }
 
-func (bc *Blockchain) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int) {
-	unspentOutputs := make(map[string][]int)
-	unspentTXs := bc.FindUnspentTransactions(pubKeyHash)
-	accumulated := 0
-
-Work:
-	for _, tx := range unspentTXs {
-		txID := hex.EncodeToString(tx.ID)
+// MineBlock mines a block of txs on top of the tip and adds it to the chain.
+func (bc *Blockchain) MineBlock(txs []*transaction.Transaction) (*Block, error) {
+	block, err := bc.NewBlockTemplate(txs)
+	if err != nil {
+		return nil, err
+	}
 
-		for outIdx, out := range tx.Vout {
-			if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
-				accumulated += out.Value
-				unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+	if err = block.Mine(); err != nil {
+		return nil, err
+	}
 
-				if accumulated >= amount {
-					break Work
-				}
-			}
-		}
+	_, err = bc.AddBlock(block)
+	if err != nil {
+		return nil, err
 	}
 
-	return accumulated, unspentOutputs
+	return block, nil
 }
 
-func (bc *Blockchain) MineBlock(txs []*transaction.Transaction) (*Block, error) {
-	var lastHash []byte
+// NewBlockTemplate verifies txs and puts them into a block extending the tip,
+// which still has to be mined.
+func (bc *Blockchain) NewBlockTemplate(txs []*transaction.Transaction) (*Block, error) {
+	var lastBlock *Block
+	var bits uint32
+	var medianTime int64
 
 	for _, tx := range txs {
 		verified, err := bc.VerifyTransaction(tx)
@@ -240,21 +239,57 @@ func (bc *Blockchain) MineBlock(txs []*transaction.Transaction) (*Block, error)
 
 	err := bc.DB.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
 
-		return nil
+		var err error
+		lastBlock, err = getStoredBlock(tx, b.Get([]byte("l")))
+		if err != nil {
+			return err
+		}
+
+		bits, err = nextBits(tx, lastBlock)
+		if err != nil {
+			return err
+		}
+
+		medianTime, err = medianTimePast(tx, lastBlock)
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	block, err := NewBlock(txs, lastHash)
-	if err != nil {
-		return nil, err
+	// Blocks mined within the same second still need to move past the median time.
+	timestamp := time.Now().Unix()
+	if timestamp <= medianTime {
+		timestamp = medianTime + 1
 	}
 
-	err = bc.DB.Update(func(tx *bolt.Tx) error {
+	return newBlockTemplate(txs, lastBlock.Hash, lastBlock.Height+1, bits, timestamp)
+}
+
+// AddBlock validates and stores a block, mined locally or received from a
+// peer. When the block makes a chain with more cumulative work than the
+// current one, the chain is reorganized to end at it and the UTXO set and
+// mempool follow. It reports whether the tip changed, a block breaking a
+// consensus rule is rejected with a ValidationError.
+func (bc *Blockchain) AddBlock(block *Block) (bool, error) {
+	var newTip []byte
+	var disconnected []*Block
+
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
+		if b.Get(block.Hash) != nil {
+			return nil
+		}
+
+		if len(block.PrevBlockHash) > 0 && b.Get(block.PrevBlockHash) == nil {
+			return ErrOrphanBlock
+		}
+
+		err := checkBlock(tx, block)
+		if err != nil {
+			return err
+		}
 
 		serialized, err := block.Serialize()
 		if err != nil {
@@ -266,16 +301,79 @@ func (bc *Blockchain) MineBlock(txs []*transaction.Transaction) (*Block, error)
 			return err
 		}
 
-		err = b.Put([]byte("l"), block.Hash)
+		index, err := indexBlock(tx, block)
+		if err != nil {
+			return err
+		}
+
+		tipIndex, err := getBlockIndex(tx, b.Get([]byte("l")))
 		if err != nil {
 			return err
 		}
 
-		bc.tip = block.Hash
+		if index.Work().Cmp(tipIndex.Work()) <= 0 {
+			return nil
+		}
+
+		disconnected, err = reorganize(tx, block)
+		if err != nil {
+			return err
+		}
 
+		newTip = block.Hash
 		return nil
 	})
+	if err != nil || newTip == nil {
+		return false, err
+	}
 
+	bc.tip = newTip
+
+	return true, bc.updateMempool(disconnected)
+}
+
+// updateMempool gives the transactions of disconnected blocks back to the
+// mempool and drops the ones the new chain made invalid.
+func (bc *Blockchain) updateMempool(disconnected []*Block) error {
+	mempool := Mempool{Blockchain: bc}
+
+	for _, block := range disconnected {
+		for _, tx := range block.Transactions {
+			if !tx.IsCoinbase() {
+				// Transactions the new chain already confirmed or conflicts with are rejected.
+				_ = mempool.Add(tx)
+			}
+		}
+	}
+
+	return mempool.Prune()
+}
+
+// GetBestHeight returns the height of the tip block.
+func (bc *Blockchain) GetBestHeight() (int, error) {
+	var lastBlock *Block
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		lastBlock, err = getStoredBlock(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastBlock.Height, nil
+}
+
+// GetBlock finds a stored block by its hash.
+func (bc *Blockchain) GetBlock(blockHash []byte) (*Block, error) {
+	var block *Block
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		block, err = getStoredBlock(tx, blockHash)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -283,6 +381,35 @@ func (bc *Blockchain) MineBlock(txs []*transaction.Transaction) (*Block, error)
 	return block, nil
 }
 
+// HasBlock reports whether a block with the given hash is stored.
+func (bc *Blockchain) HasBlock(blockHash []byte) bool {
+	found := false
+
+	_ = bc.DB.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket([]byte(blocksBucket)).Get(blockHash) != nil
+		return nil
+	})
+
+	return found
+}
+
+// GetBlockHashes returns the hashes of the chain from the tip down to genesis.
+func (bc *Blockchain) GetBlockHashes() [][]byte {
+	var blocks [][]byte
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+		blocks = append(blocks, block.Hash)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return blocks
+}
+
 func (bc *Blockchain) FindTransaction(ID []byte) (transaction.Transaction, error) {
 	bci := bc.Iterator()
 
@@ -304,80 +431,166 @@ func (bc *Blockchain) FindTransaction(ID []byte) (transaction.Transaction, error
 	return transaction.Transaction{}, errors.New("Transaction is not found")
 }
 
-func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privKey ecdsa.PrivateKey) error {
-	prevTXs := make(map[string]transaction.Transaction)
+// prevOutputs collects from the UTXO set the outputs spent by a transaction.
+func prevOutputs(btx *bolt.Tx, tx *transaction.Transaction) (map[string]transaction.TXOutput, error) {
+	prevOuts := make(map[string]transaction.TXOutput)
 
 	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
+		out, err := findUnspentOutput(btx, vin.Txid, vin.Vout)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+
+		prevOuts[transaction.OutpointKey(vin.Txid, vin.Vout)] = *out
+	}
+
+	return prevOuts, nil
+}
+
+// PrevOutputs returns the unspent outputs the inputs of tx spend, keyed by
+// transaction.OutpointKey.
+func (bc *Blockchain) PrevOutputs(tx *transaction.Transaction) (map[string]transaction.TXOutput, error) {
+	var prevOuts map[string]transaction.TXOutput
+
+	err := bc.DB.View(func(btx *bolt.Tx) error {
+		var err error
+		prevOuts, err = prevOutputs(btx, tx)
+		return err
+	})
+
+	return prevOuts, err
+}
+
+// SignTransaction signs the inputs of tx privKey can unlock, with signatures
+// covering what hashType selects.
+func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privKey ecdsa.PrivateKey, hashType transaction.SigHashType) error {
+	prevOuts, err := bc.PrevOutputs(tx)
+	if err != nil {
+		return err
 	}
 
-	return tx.Sign(privKey, prevTXs)
+	return tx.Sign(privKey, prevOuts, hashType)
 }
 
+// VerifyTransaction checks the signatures of a transaction spending outputs
+// of the UTXO set.
 func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) (bool, error) {
 	if tx.IsCoinbase() {
 		return true, nil
 	}
 
-	prevTXs := make(map[string]transaction.Transaction)
+	var prevOuts map[string]transaction.TXOutput
 
-	for _, vin := range tx.Vin {
-		prevTX, err := bc.FindTransaction(vin.Txid)
-		if err != nil {
-			return false, nil
+	err := bc.DB.View(func(btx *bolt.Tx) error {
+		var err error
+		prevOuts, err = prevOutputs(btx, tx)
+		return err
+	})
+	if errors.Is(err, ErrOutputNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return tx.Verify(prevOuts)
+}
+
+// NewUTXOTransaction pays outputs from one wallet with coins picked by
+// selector, leaving fee to the miner and sending the rest back in a single
+// change output. A replaceable one can have its fee bumped while it waits in
+// the mempool.
+func NewUTXOTransaction(from string, outputs []transaction.TXOutput, fee int, replaceable bool, selector CoinSelector, utxoset *UTXOSet) (*transaction.Transaction, *Selection, error) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wallet, err := wallets.SigningWallet(from)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, selection, err := newTransaction(from, outputs, fee, selector, utxoset)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if replaceable {
+		if err = tx.SignalReplaceable(); err != nil {
+			return nil, nil, err
 		}
-		prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
 	}
 
-	return tx.Verify(prevTXs)
+	err = utxoset.Blockchain.SignTransaction(tx, wallet.PrivateKey, transaction.SigHashAll)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, selection, nil
 }
 
-func NewUTXOTransaction(from, to string, amount int, utxoset *UTXOSet) (*transaction.Transaction, error) {
+// NewUnsignedTransaction builds the transaction paying amount from any
+// address, multisig ones included, without unlocking its inputs. The owners
+// of from sign it afterwards.
+func NewUnsignedTransaction(from, to string, amount, fee int, utxoset *UTXOSet) (*transaction.Transaction, error) {
+	output, err := transaction.NewTXOutput(amount, to)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, _, err := newTransaction(from, []transaction.TXOutput{*output}, fee, nil, utxoset)
+	return tx, err
+}
+
+// newTransaction funds outputs and fee with outputs of from picked by
+// selector, largest first when nil, sending the rest back to it as change.
+// The inputs are left unsigned.
+func newTransaction(from string, outputs []transaction.TXOutput, fee int, selector CoinSelector, utxoset *UTXOSet) (*transaction.Transaction, *Selection, error) {
 	var inputs []transaction.TXInput
-	var outputs []transaction.TXOutput
 
-	wallets, err := wallet.NewWallets()
+	amount := 0
+	for _, out := range outputs {
+		amount += out.Value
+	}
+
+	locking, err := transaction.LockingScript(from)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	wallet := wallets.GetWallet(from)
-	pubKeyHash := common.HashPubKey(wallet.PublicKey)
+	coins, err := utxoset.SpendableCoins(locking)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	acc, validOutputs := utxoset.Blockchain.FindSpendableOutputs(pubKeyHash, amount)
-	if acc < amount {
-		return nil, fmt.Errorf("ERROR: Not enough funds")
+	if selector == nil {
+		selector = LargestFirst{}
 	}
 
-	for txid, outs := range validOutputs {
-		txID, err := hex.DecodeString(txid)
-		if err != nil {
-			return nil, err
-		}
+	selection, err := selector.Select(coins, amount, fee)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		for _, out := range outs {
-			input := transaction.TXInput{Txid: txID, Vout: out, Signature: nil, PubKey: wallet.PublicKey}
-			inputs = append(inputs, input)
-		}
+	for _, coin := range selection.Coins {
+		inputs = append(inputs, transaction.TXInput{Txid: coin.Txid, Vout: coin.Vout, ScriptSig: nil})
 	}
 
-	outputs = append(outputs, *transaction.NewTXOutput(amount, to))
-	if acc > amount {
-		outputs = append(outputs, *transaction.NewTXOutput(acc-amount, from))
+	if selection.Change > 0 {
+		output, err := transaction.NewTXOutput(selection.Change, from)
+		if err != nil {
+			return nil, nil, err
+		}
+		outputs = append(outputs, *output)
 	}
 
 	tx := transaction.Transaction{ID: nil, Vin: inputs, Vout: outputs}
 	id, err := tx.Hash()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	tx.ID = id
-	utxoset.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
-
-	return &tx, nil
+	return &tx, selection, nil
 }
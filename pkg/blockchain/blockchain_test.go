@@ -0,0 +1,189 @@
+package blockchain
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/boltdb/bolt"
+)
+
+// TestMain runs the tests in a scratch directory, databases are created
+// under ./resources like the command line does.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "blockmandu-blockchain")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Mkdir(dir+"/resources", 0700); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testChains numbers the node ids of the test databases.
+var testChains int
+
+func newTestNodeID() string {
+	testChains++
+	return fmt.Sprintf("test%d", testChains)
+}
+
+func newTestWallet(t *testing.T) (*wallet.Wallet, string) {
+	t.Helper()
+
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, string(w.GetAddress())
+}
+
+// newTestChain creates a chain whose genesis block pays a new wallet.
+func newTestChain(t *testing.T) (*Blockchain, *wallet.Wallet) {
+	t.Helper()
+
+	w, address := newTestWallet(t)
+
+	bc, err := CreateBlockchain(address, newTestNodeID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+
+	if err = (UTXOSet{Blockchain: bc}).Reindex(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bc, w
+}
+
+// copyTestChain opens a copy of the database of bc, which goes on on its own.
+func copyTestChain(t *testing.T, bc *Blockchain) *Blockchain {
+	t.Helper()
+
+	nodeID := newTestNodeID()
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(dbPath(nodeID), 0600)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewBlockchain(nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.DB.Close() })
+
+	return other
+}
+
+// mineTestBlock mines txs on top of the tip with a coinbase paying address
+// the subsidy, leaving their fees unclaimed. Unlike MineBlock it lets txs
+// spend outputs created earlier in the same block.
+func mineTestBlock(t *testing.T, bc *Blockchain, address string, txs ...*transaction.Transaction) *Block {
+	t.Helper()
+
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cbtx, err := transaction.NewCoinbaseTX(address, "", height+1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := bc.NewBlockTemplate([]*transaction.Transaction{cbtx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs = append([]*transaction.Transaction{cbtx}, txs...)
+	block, err := newBlockTemplate(txs, template.PrevBlockHash, template.Height, template.Bits, template.Timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = block.Mine(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = bc.AddBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	return block
+}
+
+// newTestPayment builds and signs the transaction paying amount from the
+// coins of w in the UTXO set of bc.
+func newTestPayment(t *testing.T, bc *Blockchain, w *wallet.Wallet, to string, amount, fee int) *transaction.Transaction {
+	t.Helper()
+
+	tx, err := NewUnsignedTransaction(string(w.GetAddress()), to, amount, fee, &UTXOSet{Blockchain: bc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = bc.SignTransaction(tx, w.PrivateKey, transaction.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+// spendTestOutput builds the transaction of owner spending output vout of
+// prev, which does not need to be in the UTXO set yet, to pay amount to to.
+func spendTestOutput(t *testing.T, prev *transaction.Transaction, vout int, owner *wallet.Wallet, to string, amount int) *transaction.Transaction {
+	t.Helper()
+
+	out, err := transaction.NewTXOutput(amount, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &transaction.Transaction{Vin: []transaction.TXInput{{Txid: prev.ID, Vout: vout}}, Vout: []transaction.TXOutput{*out}}
+	if tx.ID, err = tx.Hash(); err != nil {
+		t.Fatal(err)
+	}
+
+	prevOuts := map[string]transaction.TXOutput{transaction.OutpointKey(prev.ID, vout): prev.Vout[vout]}
+	if err = tx.Sign(owner.PrivateKey, prevOuts, transaction.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+// bucketContent returns the records of a bucket of the database of bc.
+func bucketContent(t *testing.T, bc *Blockchain, bucket string) map[string]string {
+	t.Helper()
+
+	content := make(map[string]string)
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			content[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
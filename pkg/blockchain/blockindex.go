@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/big"
+
+	"github.com/boltdb/bolt"
+)
+
+const blockIndexBucket = "blockindex"
+
+// blockIndex records where a stored block sits in the block tree, the best
+// chain is the one whose tip has the most cumulative work.
+type blockIndex struct {
+	ChainWork []byte
+	Height    int
+}
+
+func (i blockIndex) Work() *big.Int {
+	return new(big.Int).SetBytes(i.ChainWork)
+}
+
+func putBlockIndex(tx *bolt.Tx, hash []byte, index blockIndex) error {
+	b, err := tx.CreateBucketIfNotExists([]byte(blockIndexBucket))
+	if err != nil {
+		return err
+	}
+
+	var buff bytes.Buffer
+	err = gob.NewEncoder(&buff).Encode(index)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(hash, buff.Bytes())
+}
+
+func getBlockIndex(tx *bolt.Tx, hash []byte) (*blockIndex, error) {
+	b := tx.Bucket([]byte(blockIndexBucket))
+	if b == nil || b.Get(hash) == nil {
+		return nil, ErrBlockNotFound
+	}
+
+	var index blockIndex
+	err := gob.NewDecoder(bytes.NewReader(b.Get(hash))).Decode(&index)
+	if err != nil {
+		return nil, err
+	}
+
+	return &index, nil
+}
+
+// indexBlock stores the index entry of a block whose parent is already indexed.
+func indexBlock(tx *bolt.Tx, block *Block) (*blockIndex, error) {
+	work := NewProofOfWork(block).Work()
+
+	if len(block.PrevBlockHash) > 0 {
+		parent, err := getBlockIndex(tx, block.PrevBlockHash)
+		if err != nil {
+			return nil, err
+		}
+
+		if block.Height != parent.Height+1 {
+			return nil, ErrBadHeight
+		}
+
+		work.Add(work, parent.Work())
+	} else if block.Height != 0 {
+		return nil, ErrBadHeight
+	}
+
+	index := blockIndex{ChainWork: work.Bytes(), Height: block.Height}
+	if err := putBlockIndex(tx, block.Hash, index); err != nil {
+		return nil, err
+	}
+
+	return &index, nil
+}
+
+// fillLegacyBlock completes a block of the first database versions, stored
+// without its height and bits: it gets the height of its position in the
+// chain and counts as mined at the initial difficulty.
+func fillLegacyBlock(block *Block, height int) {
+	block.Height = height
+	if block.Bits == 0 {
+		block.Bits = InitialBits
+	}
+}
+
+// ensureBlockIndex builds the index of the best chain for databases created
+// before blocks were indexed. Heights are counted from the genesis block,
+// the stored ones are missing from the oldest databases.
+func ensureBlockIndex(tx *bolt.Tx) error {
+	if tx.Bucket([]byte(blockIndexBucket)) != nil {
+		return nil
+	}
+
+	b := tx.Bucket([]byte(blocksBucket))
+
+	var chain []*Block
+	for hash := b.Get([]byte("l")); len(hash) > 0; {
+		block, err := deserializeStoredBlock(b.Get(hash))
+		if err != nil {
+			return err
+		}
+
+		chain = append(chain, block)
+		hash = block.PrevBlockHash
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		fillLegacyBlock(chain[i], len(chain)-1-i)
+
+		if _, err := indexBlock(tx, chain[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChainWork returns the cumulative work of the chain ending at the given block.
+func (bc *Blockchain) ChainWork(blockHash []byte) (*big.Int, error) {
+	var work *big.Int
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		index, err := getBlockIndex(tx, blockHash)
+		if err != nil {
+			return err
+		}
+
+		work = index.Work()
+		return nil
+	})
+
+	return work, err
+}
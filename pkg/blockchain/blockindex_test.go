@@ -0,0 +1,193 @@
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"testing"
+
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+// The records of the first version of the database: gob encoded blocks
+// without height nor bits, holding outputs locked to a public key hash.
+type (
+	firstVersionInput struct {
+		Txid      []byte
+		Vout      int
+		Signature []byte
+		PubKey    []byte
+	}
+	firstVersionOutput struct {
+		Value      int
+		PubKeyHash []byte
+	}
+	firstVersionTx struct {
+		ID   []byte
+		Vin  []firstVersionInput
+		Vout []firstVersionOutput
+	}
+	firstVersionBlock struct {
+		Transactions  []*firstVersionTx
+		PrevBlockHash []byte
+		Hash          []byte
+		Timestamp     int64
+		Nonce         int
+	}
+	firstVersionOutputs struct {
+		Outputs []firstVersionOutput
+	}
+)
+
+func gobEncode(t *testing.T, v interface{}) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func testPubKeyHash(t *testing.T, address string) []byte {
+	t.Helper()
+
+	locking, err := transaction.LockingScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return script.ExtractPubKeyHash(locking)
+}
+
+// newFirstVersionChain writes the database of a first version node whose
+// chain is made of the given blocks, genesis first.
+func newFirstVersionChain(t *testing.T, blocks []*firstVersionBlock) string {
+	t.Helper()
+
+	nodeID := newTestNodeID()
+	db, err := bolt.Open(dbPath(nodeID), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(blocksBucket))
+		if err != nil {
+			return err
+		}
+
+		var prev []byte
+		for i, block := range blocks {
+			block.PrevBlockHash = prev
+			block.Timestamp = int64(1600000000 + i)
+			hash := sha256.Sum256(append([]byte{byte(i)}, prev...))
+			block.Hash = hash[:]
+			prev = block.Hash
+
+			if err = b.Put(block.Hash, gobEncode(t, block)); err != nil {
+				return err
+			}
+		}
+		if err = b.Put([]byte("l"), prev); err != nil {
+			return err
+		}
+
+		// The chainstate of the first version can't be read anymore and is
+		// rebuilt from the blocks.
+		utxo, err := tx.CreateBucket([]byte(utxoBucket))
+		if err != nil {
+			return err
+		}
+		return utxo.Put(blocks[0].Transactions[0].ID, gobEncode(t, firstVersionOutputs{blocks[0].Transactions[0].Vout}))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return nodeID
+}
+
+func TestOpenFirstVersionDatabase(t *testing.T) {
+	_, alice := newTestWallet(t)
+	_, bob := newTestWallet(t)
+	alicePKH, bobPKH := testPubKeyHash(t, alice), testPubKeyHash(t, bob)
+
+	coinbase := func(id byte) *firstVersionTx {
+		return &firstVersionTx{
+			ID:   []byte{id},
+			Vin:  []firstVersionInput{{Txid: []byte{}, Vout: -1, PubKey: []byte("reward")}},
+			Vout: []firstVersionOutput{{Value: 10, PubKeyHash: alicePKH}},
+		}
+	}
+	payment := &firstVersionTx{
+		ID:   []byte{3},
+		Vin:  []firstVersionInput{{Txid: []byte{1}, Vout: 0, Signature: []byte("sig"), PubKey: []byte("key")}},
+		Vout: []firstVersionOutput{{Value: 3, PubKeyHash: bobPKH}, {Value: 7, PubKeyHash: alicePKH}},
+	}
+
+	nodeID := newFirstVersionChain(t, []*firstVersionBlock{
+		{Transactions: []*firstVersionTx{coinbase(1)}},
+		{Transactions: []*firstVersionTx{coinbase(2), payment}},
+		{Transactions: []*firstVersionTx{coinbase(4)}},
+	})
+
+	bc, err := NewBlockchain(nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { bc.DB.Close() })
+
+	height := 2
+	for it := bc.Iterator(); ; height-- {
+		block := it.Next()
+		if block.Height != height {
+			t.Errorf("block %x has height %d, want %d", block.Hash, block.Height, height)
+		}
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+	if height != 0 {
+		t.Errorf("chain ends at height %d", height)
+	}
+
+	balances := []struct {
+		address string
+		want    int
+	}{
+		{address: alice, want: 27},
+		{address: bob, want: 3},
+	}
+	for _, b := range balances {
+		locking, err := transaction.LockingScript(b.address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coins, err := (UTXOSet{Blockchain: bc}).SpendableCoins(locking)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := 0
+		for _, coin := range coins {
+			got += coin.Value
+		}
+		if got != b.want {
+			t.Errorf("balance of %s is %d, want %d", b.address, got, b.want)
+		}
+	}
+
+	if _, err = bc.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	block := mineTestBlock(t, bc, alice)
+	if block.Height != 3 {
+		t.Errorf("block mined on top has height %d, want 3", block.Height)
+	}
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/blockmandu/pkg/transaction"
+)
+
+var (
+	ErrNotReplaceable = errors.New("transaction does not opt in to replacement")
+	ErrNoChange       = errors.New("transaction has no change to take a higher fee from")
+)
+
+// BumpFee builds the replacement of the mempool transaction txid paying fee:
+// the same inputs and outputs, with the fee increase taken out of the change
+// going back to the address the inputs belong to, which only one output may
+// pay. The replacement is left unsigned.
+func (m Mempool) BumpFee(txid []byte, fee int) (*transaction.Transaction, error) {
+	tx, err := m.Get(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if !tx.IsReplaceable() {
+		return nil, ErrNotReplaceable
+	}
+
+	oldFee, err := m.Fee(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if fee <= oldFee {
+		return nil, fmt.Errorf("%w: fee of %d, current fee of %d", ErrReplacementFee, fee, oldFee)
+	}
+
+	prevOuts, err := m.Blockchain.PrevOutputs(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Change pays back to the locking script of the inputs, which must be
+	// shared by all of them to tell it apart.
+	var locking []byte
+	for _, vin := range tx.Vin {
+		prevOut := prevOuts[transaction.OutpointKey(vin.Txid, vin.Vout)]
+		if locking != nil && !bytes.Equal(prevOut.ScriptPubKey, locking) {
+			return nil, fmt.Errorf("%w: inputs belong to several addresses", ErrNoChange)
+		}
+		locking = prevOut.ScriptPubKey
+	}
+
+	// A payment to the same address would look like change too, taking the
+	// increase out of it would cut the payment.
+	change := -1
+	for i, out := range tx.Vout {
+		if !bytes.Equal(out.ScriptPubKey, locking) {
+			continue
+		}
+		if change >= 0 {
+			return nil, fmt.Errorf("%w: several outputs pay back to the address of the inputs", ErrNoChange)
+		}
+		change = i
+	}
+
+	if change < 0 {
+		return nil, ErrNoChange
+	}
+
+	increase := fee - oldFee
+	if tx.Vout[change].Value < increase {
+		return nil, fmt.Errorf("%w: change of %d is less than the increase of %d", ErrNoChange, tx.Vout[change].Value, increase)
+	}
+
+	replacement := tx.TrimmedCopy()
+	replacement.Vout = append([]transaction.TXOutput{}, tx.Vout...)
+	replacement.Vout[change].Value -= increase
+	if replacement.Vout[change].Value == 0 {
+		replacement.Vout = append(replacement.Vout[:change], replacement.Vout[change+1:]...)
+	}
+
+	if replacement.ID, err = replacement.ComputeID(); err != nil {
+		return nil, err
+	}
+
+	return &replacement, nil
+}
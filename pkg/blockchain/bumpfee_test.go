@@ -0,0 +1,143 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+// newTestRBFPayment is newTestPayment, opting in to replacement when
+// replaceable.
+func newTestRBFPayment(t *testing.T, bc *Blockchain, w *wallet.Wallet, to string, amount, fee int, replaceable bool) *transaction.Transaction {
+	t.Helper()
+
+	tx, err := NewUnsignedTransaction(string(w.GetAddress()), to, amount, fee, &UTXOSet{Blockchain: bc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if replaceable {
+		if err = tx.SignalReplaceable(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = bc.SignTransaction(tx, w.PrivateKey, transaction.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestMempoolReplacement(t *testing.T) {
+	tests := []struct {
+		name        string
+		replaceable bool
+		fee         int
+		err         error
+	}{
+		{name: "higher fee", replaceable: true, fee: 3},
+		{name: "lower fee", replaceable: true, fee: 1, err: ErrReplacementFee},
+		{name: "not opted in", replaceable: false, fee: 3, err: ErrMempoolConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, w := newTestChain(t)
+			_, to := newTestWallet(t)
+			mempool := Mempool{Blockchain: bc}
+
+			original := newTestRBFPayment(t, bc, w, to, 3, 2, tt.replaceable)
+			if err := mempool.Add(original); err != nil {
+				t.Fatal(err)
+			}
+
+			// The replacement spends the same coin, paying the fee
+			// difference out of the change.
+			replacement := original.TrimmedCopy()
+			replacement.Vout = append([]transaction.TXOutput{}, original.Vout...)
+			replacement.Vout[len(replacement.Vout)-1].Value -= tt.fee - 2
+			if err := replacement.SignalReplaceable(); err != nil {
+				t.Fatal(err)
+			}
+			if err := bc.SignTransaction(&replacement, w.PrivateKey, transaction.SigHashAll); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mempool.Add(&replacement); !errors.Is(err, tt.err) {
+				t.Fatalf("Add = %v, want %v", err, tt.err)
+			}
+
+			_, err := mempool.Get(original.ID)
+			if replaced := err != nil; replaced != (tt.err == nil) {
+				t.Errorf("original replaced: %v, want %v", replaced, tt.err == nil)
+			}
+		})
+	}
+}
+
+func TestBumpFee(t *testing.T) {
+	tests := []struct {
+		name        string
+		toSelf      bool
+		replaceable bool
+		amount      int
+		fee         int
+		err         error
+	}{
+		{name: "out of the change", replaceable: true, amount: 3, fee: 3},
+		{name: "whole change", replaceable: true, amount: 3, fee: 7},
+		{name: "change too small", replaceable: true, amount: 8, fee: 3, err: ErrNoChange},
+		{name: "payment to the own address", toSelf: true, replaceable: true, amount: 3, fee: 3, err: ErrNoChange},
+		{name: "not opted in", amount: 3, fee: 3, err: ErrNotReplaceable},
+		{name: "fee not higher", replaceable: true, amount: 3, fee: 1, err: ErrReplacementFee},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, w := newTestChain(t)
+			_, to := newTestWallet(t)
+			if tt.toSelf {
+				to = string(w.GetAddress())
+			}
+			mempool := Mempool{Blockchain: bc}
+
+			original := newTestRBFPayment(t, bc, w, to, tt.amount, 1, tt.replaceable)
+			if err := mempool.Add(original); err != nil {
+				t.Fatal(err)
+			}
+
+			replacement, err := mempool.BumpFee(original.ID, tt.fee)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("BumpFee = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := replacement.Vout[0]; got.Value != tt.amount {
+				t.Errorf("payment of %d, want %d", got.Value, tt.amount)
+			}
+
+			if err = bc.SignTransaction(replacement, w.PrivateKey, transaction.SigHashAll); err != nil {
+				t.Fatal(err)
+			}
+			if err = mempool.Add(replacement); err != nil {
+				t.Fatal(err)
+			}
+
+			fee, err := mempool.Fee(replacement)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fee != tt.fee {
+				t.Errorf("fee of %d, want %d", fee, tt.fee)
+			}
+			if _, err = mempool.Get(original.ID); err == nil {
+				t.Error("original still in the mempool")
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// bnbMaxTries bounds the subsets branch and bound looks at, the search is
+// exponential in the number of coins.
+const bnbMaxTries = 100000
+
+var (
+	ErrNotEnoughFunds      = errors.New("not enough funds")
+	ErrNoExactMatch        = errors.New("no set of coins matches the amount without change")
+	ErrUnknownCoinSelector = errors.New("unknown coin selection strategy")
+)
+
+// Coin is an unspent output a wallet can fund a transaction with.
+type Coin struct {
+	Txid  []byte
+	Vout  int
+	Value int
+}
+
+// Selection is the coins chosen to pay an amount and a fee, with what they
+// leave over as change.
+type Selection struct {
+	Coins  []Coin
+	Value  int
+	Change int
+	Fee    int
+}
+
+// CoinSelector picks which coins pay amount and fee. Every transaction spends
+// at least one coin, even one without amount or fee.
+type CoinSelector interface {
+	Select(coins []Coin, amount, fee int) (*Selection, error)
+}
+
+// coinSelectors are the strategies by the names the command line knows them.
+var coinSelectors = map[string]CoinSelector{
+	"largest-first":    LargestFirst{},
+	"smallest-first":   SmallestFirst{},
+	"branch-and-bound": BranchAndBound{},
+	"random":           Random{},
+}
+
+// ParseCoinSelector returns the strategy called name: largest-first,
+// smallest-first, branch-and-bound or random.
+func ParseCoinSelector(name string) (CoinSelector, error) {
+	selector, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCoinSelector, name)
+	}
+
+	return selector, nil
+}
+
+// LargestFirst spends the biggest coins, using as few inputs as possible.
+type LargestFirst struct{}
+
+func (LargestFirst) Select(coins []Coin, amount, fee int) (*Selection, error) {
+	sorted := sortCoins(coins)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	return accumulate(sorted, amount, fee)
+}
+
+// SmallestFirst spends the smallest coins, consolidating them.
+type SmallestFirst struct{}
+
+func (SmallestFirst) Select(coins []Coin, amount, fee int) (*Selection, error) {
+	return accumulate(sortCoins(coins), amount, fee)
+}
+
+// Random spends coins in random order, so that the inputs tell less about
+// the wallet.
+type Random struct{}
+
+func (Random) Select(coins []Coin, amount, fee int) (*Selection, error) {
+	shuffled := append([]Coin{}, coins...)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	return accumulate(shuffled, amount, fee)
+}
+
+// BranchAndBound searches for coins paying amount and fee exactly, leaving
+// no change output. Up to MaxExcess more is accepted and left to the miner.
+type BranchAndBound struct {
+	MaxExcess int
+}
+
+func (s BranchAndBound) Select(coins []Coin, amount, fee int) (*Selection, error) {
+	target := amount + fee
+
+	// Largest first, so that the search reaches the target in few steps.
+	sorted := sortCoins(coins)
+	for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+		sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+
+	// remaining[i] is the value of the coins from i on.
+	remaining := make([]int, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + sorted[i].Value
+	}
+
+	var chosen, best []int
+	bestExcess := -1
+	tries := 0
+
+	var search func(i, sum int)
+	search = func(i, sum int) {
+		tries++
+		if tries > bnbMaxTries || bestExcess == 0 {
+			return
+		}
+
+		if sum >= target && len(chosen) > 0 {
+			if excess := sum - target; excess <= s.MaxExcess && (bestExcess < 0 || excess < bestExcess) {
+				best, bestExcess = append([]int{}, chosen...), excess
+			}
+			return
+		}
+
+		if i == len(sorted) || sum+remaining[i] < target {
+			return
+		}
+
+		chosen = append(chosen, i)
+		search(i+1, sum+sorted[i].Value)
+		chosen = chosen[:len(chosen)-1]
+
+		// Leaving out a coin and taking one of the same value after it
+		// gives the same sums again.
+		next := i + 1
+		for next < len(sorted) && sorted[next].Value == sorted[i].Value {
+			next++
+		}
+		search(next, sum)
+	}
+	search(0, 0)
+
+	if best == nil {
+		return nil, ErrNoExactMatch
+	}
+
+	selection := &Selection{Fee: fee + bestExcess}
+	for _, i := range best {
+		selection.Coins = append(selection.Coins, sorted[i])
+		selection.Value += sorted[i].Value
+	}
+
+	return selection, nil
+}
+
+// sortCoins returns a copy of coins sorted by ascending value.
+func sortCoins(coins []Coin) []Coin {
+	sorted := append([]Coin{}, coins...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+
+	return sorted
+}
+
+// accumulate takes coins in order until they pay amount and fee, the rest
+// being change.
+func accumulate(coins []Coin, amount, fee int) (*Selection, error) {
+	selection := &Selection{Fee: fee}
+
+	for _, coin := range coins {
+		if selection.Value >= amount+fee && len(selection.Coins) > 0 {
+			break
+		}
+
+		selection.Coins = append(selection.Coins, coin)
+		selection.Value += coin.Value
+	}
+
+	if selection.Value < amount+fee || len(selection.Coins) == 0 {
+		return nil, ErrNotEnoughFunds
+	}
+
+	selection.Change = selection.Value - amount - fee
+	return selection, nil
+}
@@ -0,0 +1,128 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testCoins returns a coin of each value, from distinct transactions.
+func testCoins(values ...int) []Coin {
+	coins := make([]Coin, len(values))
+	for i, value := range values {
+		coins[i] = Coin{Txid: []byte(fmt.Sprintf("transaction %d", i)), Vout: i, Value: value}
+	}
+
+	return coins
+}
+
+// coinValues returns the values of coins in ascending order.
+func coinValues(coins []Coin) []int {
+	var values []int
+	for _, coin := range coins {
+		values = append(values, coin.Value)
+	}
+	sort.Ints(values)
+
+	return values
+}
+
+func TestCoinSelectors(t *testing.T) {
+	coins := testCoins(5, 1, 10, 2)
+
+	tests := []struct {
+		name     string
+		selector CoinSelector
+		noCoins  bool
+		amount   int
+		fee      int
+		values   []int
+		change   int
+		wantFee  int
+		err      error
+	}{
+		{name: "largest first", selector: LargestFirst{}, amount: 6, fee: 1, values: []int{10}, change: 3, wantFee: 1},
+		{name: "largest first needing several", selector: LargestFirst{}, amount: 13, fee: 1, values: []int{5, 10}, change: 1, wantFee: 1},
+		{name: "smallest first", selector: SmallestFirst{}, amount: 6, fee: 1, values: []int{1, 2, 5}, change: 1, wantFee: 1},
+		{name: "nothing to pay", selector: SmallestFirst{}, values: []int{1}, change: 1},
+		{name: "not enough funds", selector: LargestFirst{}, amount: 18, fee: 1, err: ErrNotEnoughFunds},
+		{name: "no coins", selector: SmallestFirst{}, noCoins: true, err: ErrNotEnoughFunds},
+		{name: "exact match", selector: BranchAndBound{}, amount: 6, fee: 1, values: []int{2, 5}, wantFee: 1},
+		{name: "exact match of every coin", selector: BranchAndBound{}, amount: 17, fee: 1, values: []int{1, 2, 5, 10}, wantFee: 1},
+		{name: "no exact match", selector: BranchAndBound{}, amount: 13, fee: 1, err: ErrNoExactMatch},
+		{name: "excess left to the miner", selector: BranchAndBound{MaxExcess: 1}, amount: 13, fee: 1, values: []int{5, 10}, wantFee: 2},
+		{name: "more than the coins", selector: BranchAndBound{MaxExcess: 1}, amount: 18, fee: 1, err: ErrNoExactMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available := coins
+			if tt.noCoins {
+				available = nil
+			}
+
+			selection, err := tt.selector.Select(available, tt.amount, tt.fee)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Select = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := coinValues(selection.Coins); !reflect.DeepEqual(got, tt.values) {
+				t.Errorf("coins of %v, want %v", got, tt.values)
+			}
+			if selection.Change != tt.change || selection.Fee != tt.wantFee {
+				t.Errorf("change of %d and fee of %d, want %d and %d", selection.Change, selection.Fee, tt.change, tt.wantFee)
+			}
+			if total := tt.amount + selection.Fee + selection.Change; selection.Value != total {
+				t.Errorf("coins worth %d pay %d", selection.Value, total)
+			}
+		})
+	}
+}
+
+func TestRandomCoinSelector(t *testing.T) {
+	coins := testCoins(5, 1, 10, 2, 3, 8)
+
+	for i := 0; i < 50; i++ {
+		selection, err := Random{}.Select(coins, 12, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		seen := make(map[string]bool)
+		value := 0
+		for _, coin := range selection.Coins {
+			key := fmt.Sprintf("%s:%d", coin.Txid, coin.Vout)
+			if seen[key] {
+				t.Fatalf("coin %s selected twice", key)
+			}
+			seen[key] = true
+			value += coin.Value
+		}
+
+		if value != selection.Value || value < 13 || selection.Change != value-13 {
+			t.Fatalf("coins worth %d, selection of %d with change of %d", value, selection.Value, selection.Change)
+		}
+	}
+
+	if _, err := (Random{}).Select(coins, 29, 1); !errors.Is(err, ErrNotEnoughFunds) {
+		t.Errorf("Select = %v, want %v", err, ErrNotEnoughFunds)
+	}
+}
+
+func TestParseCoinSelector(t *testing.T) {
+	for name, want := range coinSelectors {
+		got, err := ParseCoinSelector(name)
+		if err != nil || got != want {
+			t.Errorf("ParseCoinSelector(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseCoinSelector("first-fit"); !errors.Is(err, ErrUnknownCoinSelector) {
+		t.Errorf("ParseCoinSelector = %v, want %v", err, ErrUnknownCoinSelector)
+	}
+}
@@ -0,0 +1,129 @@
+package blockchain
+
+import (
+	"math/big"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// initialTargetBits is the number of leading zero bits a hash needs at the
+	// genesis block, it is also the easiest difficulty the chain allows.
+	initialTargetBits = 16
+	// retargetInterval is the number of blocks between difficulty adjustments.
+	retargetInterval = 10
+	// targetBlockTime is the expected number of seconds between two blocks.
+	targetBlockTime = 10
+	// maxRetargetFactor bounds how much a single adjustment can change the target.
+	maxRetargetFactor = 4
+)
+
+var powLimit = new(big.Int).Lsh(big.NewInt(1), 256-initialTargetBits)
+
+// InitialBits is the compact target of the genesis block.
+var InitialBits = BigToCompact(powLimit)
+
+// CompactToBig expands a compact target: the high byte is the length of the
+// target in bytes and the low three bytes are its most significant bytes.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	exponent := uint(compact >> 24)
+
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		return big.NewInt(int64(mantissa))
+	}
+
+	target := big.NewInt(int64(mantissa))
+	return target.Lsh(target, 8*(exponent-3))
+}
+
+// BigToCompact encodes a target in its compact form, dropping all but its
+// three most significant bytes.
+func BigToCompact(target *big.Int) uint32 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint(len(target.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		shifted := new(big.Int).Rsh(target, 8*(exponent-3))
+		mantissa = uint32(shifted.Uint64())
+	}
+
+	// The mantissa is read as signed, keep its sign bit clear.
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	return uint32(exponent<<24) | mantissa
+}
+
+// nextBits returns the bits a block following parent has to carry. The
+// target is kept for retargetInterval blocks, then scaled by how long the
+// last period actually took compared to targetBlockTime per block.
+func nextBits(tx *bolt.Tx, parent *Block) (uint32, error) {
+	if parent == nil {
+		return InitialBits, nil
+	}
+
+	height := parent.Height + 1
+	if height%retargetInterval != 0 {
+		return parent.Bits, nil
+	}
+
+	first := parent
+	for i := 0; i < retargetInterval-1; i++ {
+		var err error
+		first, err = getStoredBlock(tx, first.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	expected := int64((parent.Height - first.Height) * targetBlockTime)
+	actual := parent.Timestamp - first.Timestamp
+	if actual < expected/maxRetargetFactor {
+		actual = expected / maxRetargetFactor
+	}
+	if actual > expected*maxRetargetFactor {
+		actual = expected * maxRetargetFactor
+	}
+
+	target := CompactToBig(parent.Bits)
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(expected))
+
+	if target.Cmp(powLimit) > 0 {
+		target.Set(powLimit)
+	}
+
+	return BigToCompact(target), nil
+}
+
+// RequiredBits returns the bits the chain requires from the given block,
+// computed from its ancestors.
+func (bc *Blockchain) RequiredBits(block *Block) (uint32, error) {
+	var bits uint32
+
+	err := bc.DB.View(func(tx *bolt.Tx) error {
+		var parent *Block
+		if len(block.PrevBlockHash) > 0 {
+			var err error
+			parent, err = getStoredBlock(tx, block.PrevBlockHash)
+			if err != nil {
+				return err
+			}
+		}
+
+		var err error
+		bits, err = nextBits(tx, parent)
+		return err
+	})
+
+	return bits, err
+}
@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestCompactTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		compact uint32
+		target  string
+		// truncated targets only expand back to their three most
+		// significant bytes.
+		truncated bool
+	}{
+		{name: "zero", compact: 0, target: "0"},
+		{name: "short target", compact: 0x03123456, target: "123456"},
+		{name: "long target", compact: 0x1d00ffff, target: "ffff" + strings.Repeat("0", 52)},
+		{name: "initial target", compact: InitialBits, target: "1" + strings.Repeat("0", 60)},
+		{name: "sign bit moves to the exponent", compact: 0x02008000, target: "80"},
+		{name: "sign bit of a long target", compact: 0x05009234, target: "92340000"},
+		{name: "low bytes are dropped", compact: 0x05012345, target: "123456789", truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, ok := new(big.Int).SetString(tt.target, 16)
+			if !ok {
+				t.Fatalf("bad target %s", tt.target)
+			}
+
+			if got := BigToCompact(target); got != tt.compact {
+				t.Errorf("BigToCompact(%x) = %#08x, want %#08x", target, got, tt.compact)
+			}
+
+			if tt.truncated {
+				return
+			}
+			if got := CompactToBig(tt.compact); got.Cmp(target) != 0 {
+				t.Errorf("CompactToBig(%#08x) = %x, want %x", tt.compact, got, target)
+			}
+		})
+	}
+}
+
+func TestNextBits(t *testing.T) {
+	const bits = 0x1d00ffff
+	// period is the expected duration of a retarget period, in seconds.
+	const period = (retargetInterval - 1) * targetBlockTime
+
+	tests := []struct {
+		name string
+		// blocks of the chain, spacing seconds apart, all carrying bits.
+		blocks  int
+		spacing int64
+		bits    uint32
+		// the expected target is the one of bits scaled by num/den.
+		num, den int64
+	}{
+		{name: "kept between retargets", blocks: 5, spacing: 1, bits: bits, num: 1, den: 1},
+		{name: "blocks on time", blocks: retargetInterval, spacing: targetBlockTime, bits: bits, num: 1, den: 1},
+		{name: "blocks twice as fast", blocks: retargetInterval, spacing: targetBlockTime / 2, bits: bits, num: 1, den: 2},
+		{name: "blocks twice as slow", blocks: retargetInterval, spacing: targetBlockTime * 2, bits: bits, num: 2, den: 1},
+		{name: "faster blocks are clamped", blocks: retargetInterval, spacing: 0, bits: bits, num: period / maxRetargetFactor, den: period},
+		{name: "slower blocks are clamped", blocks: retargetInterval, spacing: targetBlockTime * 10, bits: bits, num: maxRetargetFactor, den: 1},
+		{name: "never easier than the limit", blocks: retargetInterval, spacing: targetBlockTime * 2, bits: InitialBits, num: 1, den: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "blocks.db"), 0600, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			err = db.Update(func(tx *bolt.Tx) error {
+				b, err := tx.CreateBucket([]byte(blocksBucket))
+				if err != nil {
+					return err
+				}
+
+				var parent *Block
+				for i := 0; i < tt.blocks; i++ {
+					block := &Block{Height: i}
+					block.Bits = tt.bits
+					block.Timestamp = 1600000000 + int64(i)*tt.spacing
+					if parent != nil {
+						block.PrevBlockHash = parent.Hash
+					}
+					block.Hash = block.BlockHeader.Hash()
+
+					serialized, err := block.Serialize()
+					if err != nil {
+						return err
+					}
+					if err = b.Put(block.Hash, serialized); err != nil {
+						return err
+					}
+					parent = block
+				}
+
+				got, err := nextBits(tx, parent)
+				if err != nil {
+					return err
+				}
+
+				target := CompactToBig(tt.bits)
+				target.Mul(target, big.NewInt(tt.num))
+				target.Div(target, big.NewInt(tt.den))
+				if want := BigToCompact(target); got != want {
+					t.Errorf("nextBits = %#08x, want %#08x", got, want)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+const (
+	blockVersion = witnessBlockVersion
+	hashLength   = 32
+	// headerLength is the size of a serialized header: version (4), previous
+	// block hash (32), merkle root (32), timestamp (8), bits (4), nonce (8).
+	headerLength = 4 + hashLength + hashLength + 8 + 4 + 8
+	nonceOffset  = headerLength - 8
+)
+
+var ErrBadHeader = errors.New("serialized block header is malformed")
+
+// BlockHeader is the part of a block that is hashed and mined. It commits to
+// the transactions through their merkle root, so a header alone is enough to
+// check the proof of work and the link to the previous block.
+type BlockHeader struct {
+	PrevBlockHash []byte
+	MerkleRoot    []byte
+	Timestamp     int64
+	Nonce         int64
+	Version       int32
+	Bits          uint32
+}
+
+// Serialize encodes the header in its fixed 88 byte big-endian layout, hashes
+// are written as 32 bytes and the genesis previous hash as zeros.
+func (h *BlockHeader) Serialize() []byte {
+	data := make([]byte, headerLength)
+
+	binary.BigEndian.PutUint32(data[0:4], uint32(h.Version))
+	copy(data[4:4+hashLength], h.PrevBlockHash)
+	copy(data[4+hashLength:4+2*hashLength], h.MerkleRoot)
+	binary.BigEndian.PutUint64(data[4+2*hashLength:], uint64(h.Timestamp))
+	binary.BigEndian.PutUint32(data[12+2*hashLength:], h.Bits)
+	binary.BigEndian.PutUint64(data[nonceOffset:], uint64(h.Nonce))
+
+	return data
+}
+
+// Hash returns the SHA-256 of the serialized header, the block hash.
+func (h *BlockHeader) Hash() []byte {
+	hash := sha256.Sum256(h.Serialize())
+	return hash[:]
+}
+
+func DeserializeBlockHeader(data []byte) (*BlockHeader, error) {
+	if len(data) != headerLength {
+		return nil, ErrBadHeader
+	}
+
+	h := &BlockHeader{
+		Version:    int32(binary.BigEndian.Uint32(data[0:4])),
+		MerkleRoot: append([]byte{}, data[4+hashLength:4+2*hashLength]...),
+		Timestamp:  int64(binary.BigEndian.Uint64(data[4+2*hashLength:])),
+		Bits:       binary.BigEndian.Uint32(data[12+2*hashLength:]),
+		Nonce:      int64(binary.BigEndian.Uint64(data[nonceOffset:])),
+	}
+
+	prevHash := data[4 : 4+hashLength]
+	for _, b := range prevHash {
+		if b != 0 {
+			h.PrevBlockHash = append([]byte{}, prevHash...)
+			break
+		}
+	}
+
+	return h, nil
+}
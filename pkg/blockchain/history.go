@@ -0,0 +1,257 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+// historyBucket indexes the transactions of the chain by the locking scripts
+// they pay to or spend from: one nested bucket per script, keyed by block
+// height and position in the block. It is built from the chain when first
+// needed and follows blocks connected and disconnected from then on.
+const historyBucket = "history"
+
+// HistoryEntry is a transaction of the chain paying to or spending from an
+// address.
+type HistoryEntry struct {
+	Txid      []byte
+	Height    int
+	Timestamp int64
+	// Received and Sent are the values of the outputs paying to the address
+	// and of the ones of it the transaction spent, Delta their difference.
+	Received int
+	Sent     int
+	Delta    int
+	// Counterparties are the locking scripts of the outputs the other
+	// addresses spent when the address only received, and of the outputs it
+	// paid to when it spent. Coinbases have none.
+	Counterparties [][]byte
+	Coinbase       bool
+	Confirmations  int
+}
+
+// History returns the transactions of the chain involving address, oldest
+// first.
+func (bc *Blockchain) History(address string) ([]HistoryEntry, error) {
+	locking, err := transaction.LockingScript(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+
+	err = bc.DB.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(historyBucket)) == nil {
+			if err := buildHistory(tx); err != nil {
+				return err
+			}
+		}
+
+		tip, err := getStoredBlock(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket([]byte(historyBucket)).Bucket(locking)
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			entry, err := deserializeHistoryEntry(v)
+			if err != nil {
+				return err
+			}
+
+			entry.Height = int(binary.BigEndian.Uint32(k[:4]))
+			entry.Coinbase = binary.BigEndian.Uint32(k[4:]) == 0
+			entry.Confirmations = tip.Height - entry.Height + 1
+			entries = append(entries, entry)
+
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// buildHistory indexes the main chain, from the genesis block to the tip.
+func buildHistory(tx *bolt.Tx) error {
+	if _, err := tx.CreateBucket([]byte(historyBucket)); err != nil {
+		return err
+	}
+
+	var chain []*Block
+	hash := tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+	for len(hash) > 0 {
+		block, err := getStoredBlock(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		chain = append(chain, block)
+		hash = block.PrevBlockHash
+	}
+
+	// The outputs spent by each block are found among the ones created by
+	// the blocks before it.
+	outputs := make(map[string]transaction.TXOutput)
+	for i := len(chain) - 1; i >= 0; i-- {
+		block := chain[i]
+
+		var spent []spentOutput
+		for _, btx := range block.Transactions {
+			if !btx.IsCoinbase() {
+				for _, vin := range btx.Vin {
+					op := transaction.OutpointKey(vin.Txid, vin.Vout)
+					spent = append(spent, spentOutput{Txid: vin.Txid, Vout: vin.Vout, Output: outputs[op]})
+					delete(outputs, op)
+				}
+			}
+
+			for outIdx, out := range btx.Vout {
+				outputs[transaction.OutpointKey(btx.ID, outIdx)] = out
+			}
+		}
+
+		if err := indexHistory(tx, block, spent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexHistory records the transactions of a connected block, spent holding
+// the outputs its inputs spent in order. Nothing is recorded until the index
+// is built.
+func indexHistory(tx *bolt.Tx, block *Block, spent []spentOutput) error {
+	b := tx.Bucket([]byte(historyBucket))
+	if b == nil {
+		return nil
+	}
+
+	return forEachHistoryEntry(block, spent, func(locking, key []byte, entry HistoryEntry) error {
+		scriptB, err := b.CreateBucketIfNotExists(locking)
+		if err != nil {
+			return err
+		}
+
+		return scriptB.Put(key, serializeHistoryEntry(entry))
+	})
+}
+
+// unindexHistory removes what indexHistory recorded for a block being
+// disconnected.
+func unindexHistory(tx *bolt.Tx, block *Block, spent []spentOutput) error {
+	b := tx.Bucket([]byte(historyBucket))
+	if b == nil {
+		return nil
+	}
+
+	return forEachHistoryEntry(block, spent, func(locking, key []byte, _ HistoryEntry) error {
+		scriptB := b.Bucket(locking)
+		if scriptB == nil {
+			return nil
+		}
+
+		return scriptB.Delete(key)
+	})
+}
+
+// forEachHistoryEntry calls fn with the entry of every transaction of block
+// for every locking script it involves, and the entry's key.
+func forEachHistoryEntry(block *Block, spent []spentOutput, fn func(locking, key []byte, entry HistoryEntry) error) error {
+	next := 0
+	for pos, btx := range block.Transactions {
+		received := make(map[string]int)
+		sent := make(map[string]int)
+
+		var inputs []spentOutput
+		if !btx.IsCoinbase() {
+			inputs = spent[next : next+len(btx.Vin)]
+			next += len(btx.Vin)
+		}
+
+		for _, so := range inputs {
+			sent[string(so.Output.ScriptPubKey)] += so.Output.Value
+		}
+		for _, out := range btx.Vout {
+			if !out.IsUnspendable() {
+				received[string(out.ScriptPubKey)] += out.Value
+			}
+		}
+
+		key := binary.BigEndian.AppendUint32(nil, uint32(block.Height))
+		key = binary.BigEndian.AppendUint32(key, uint32(pos))
+
+		involved := make(map[string]bool)
+		for locking := range sent {
+			involved[locking] = true
+		}
+		for locking := range received {
+			involved[locking] = true
+		}
+
+		for locking := range involved {
+			if locking == "" {
+				continue
+			}
+
+			entry := HistoryEntry{Txid: btx.ID, Timestamp: block.Timestamp, Received: received[locking], Sent: sent[locking]}
+
+			// Senders for what was received, recipients for what was spent.
+			counterparties := sent
+			if _, spending := sent[locking]; spending {
+				counterparties = received
+			}
+			for other := range counterparties {
+				if other != locking {
+					entry.Counterparties = append(entry.Counterparties, []byte(other))
+				}
+			}
+			sort.Slice(entry.Counterparties, func(i, j int) bool {
+				return bytes.Compare(entry.Counterparties[i], entry.Counterparties[j]) < 0
+			})
+
+			if err := fn([]byte(locking), key, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// serializeHistoryEntry encodes an entry canonically: version uint32, txid
+// bytes | timestamp int64 | received int64 | sent int64 | uint32 count, then
+// the counterparty scripts as bytes. Height and position are the key.
+func serializeHistoryEntry(entry HistoryEntry) []byte {
+	e := common.NewEncoder()
+	e.PutBytes(entry.Txid)
+	e.PutInt64(entry.Timestamp)
+	e.PutInt64(int64(entry.Received))
+	e.PutInt64(int64(entry.Sent))
+	e.PutUint32(uint32(len(entry.Counterparties)))
+	for _, locking := range entry.Counterparties {
+		e.PutBytes(locking)
+	}
+
+	return e.Bytes()
+}
+
+func deserializeHistoryEntry(data []byte) (HistoryEntry, error) {
+	d := common.NewDecoder(data)
+	entry := HistoryEntry{Txid: d.Bytes(), Timestamp: d.Int64(), Received: int(d.Int64()), Sent: int(d.Int64())}
+	entry.Delta = entry.Received - entry.Sent
+	for n := d.Count(4); n > 0; n-- {
+		entry.Counterparties = append(entry.Counterparties, d.Bytes())
+	}
+
+	return entry, d.Finish()
+}
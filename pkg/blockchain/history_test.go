@@ -0,0 +1,126 @@
+package blockchain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+)
+
+// testHistory returns the history of address in bc.
+func testHistory(t *testing.T, bc *Blockchain, address string) []HistoryEntry {
+	t.Helper()
+
+	entries, err := bc.History(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return entries
+}
+
+func testLocking(t *testing.T, address string) []byte {
+	t.Helper()
+
+	locking, err := transaction.LockingScript(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return locking
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		// built has the index built before the blocks are mined, which then
+		// index themselves. Otherwise History builds it from the chain.
+		built bool
+	}{
+		{name: "indexed as blocks are connected", built: true},
+		{name: "built on an existing chain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, w := newTestChain(t)
+			alice := string(w.GetAddress())
+			_, bob := newTestWallet(t)
+			_, miner := newTestWallet(t)
+
+			if tt.built {
+				testHistory(t, bc, alice)
+			}
+
+			genesis, err := bc.GetBlock(bc.GetBlockHashes()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			payment := newTestPayment(t, bc, w, bob, 3, 1)
+			block := mineTestBlock(t, bc, miner, payment)
+			last := mineTestBlock(t, bc, miner)
+
+			want := map[string][]HistoryEntry{
+				alice: {
+					{Txid: genesis.Transactions[0].ID, Height: 0, Timestamp: genesis.Timestamp, Received: 10, Delta: 10, Coinbase: true, Confirmations: 3},
+					{Txid: payment.ID, Height: 1, Timestamp: block.Timestamp, Received: 6, Sent: 10, Delta: -4, Counterparties: [][]byte{testLocking(t, bob)}, Confirmations: 2},
+				},
+				bob: {
+					{Txid: payment.ID, Height: 1, Timestamp: block.Timestamp, Received: 3, Delta: 3, Counterparties: [][]byte{testLocking(t, alice)}, Confirmations: 2},
+				},
+				miner: {
+					{Txid: block.Transactions[0].ID, Height: 1, Timestamp: block.Timestamp, Received: 10, Delta: 10, Coinbase: true, Confirmations: 2},
+					{Txid: last.Transactions[0].ID, Height: 2, Timestamp: last.Timestamp, Received: 10, Delta: 10, Coinbase: true, Confirmations: 1},
+				},
+			}
+
+			for address, entries := range want {
+				if got := testHistory(t, bc, address); !reflect.DeepEqual(got, entries) {
+					t.Errorf("history of %s:\n%+v\nwant\n%+v", address, got, entries)
+				}
+			}
+		})
+	}
+}
+
+func TestHistoryFollowsReorganization(t *testing.T) {
+	bc, w := newTestChain(t)
+	other := copyTestChain(t, bc)
+	alice := string(w.GetAddress())
+	_, bob := newTestWallet(t)
+	_, miner := newTestWallet(t)
+
+	testHistory(t, bc, alice)
+	mineTestBlock(t, bc, miner, newTestPayment(t, bc, w, bob, 3, 1))
+	if entries := testHistory(t, bc, bob); len(entries) != 1 {
+		t.Fatalf("history of the recipient has %d entries, want 1", len(entries))
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := bc.AddBlock(mineTestBlock(t, other, miner)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tip := bc.GetBlockHashes()[0]; !bytes.Equal(tip, other.GetBlockHashes()[0]) {
+		t.Fatalf("chain not reorganized, tip is %x", tip)
+	}
+
+	if entries := testHistory(t, bc, bob); len(entries) != 0 {
+		t.Errorf("history of the recipient holds the disconnected payment: %+v", entries)
+	}
+
+	// The index kept across the reorganization matches the one built from
+	// the chain.
+	for _, address := range []string{alice, bob, miner} {
+		got, want := testHistory(t, bc, address), testHistory(t, other, address)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("history of %s:\n%+v\nwant\n%+v", address, got, want)
+		}
+	}
+
+	entries := testHistory(t, bc, alice)
+	if len(entries) != 1 || entries[0].Height != 0 || entries[0].Confirmations != 3 {
+		t.Errorf("history of the genesis recipient is %+v, want the genesis coinbase with 3 confirmations", entries)
+	}
+}
@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+var (
+	ErrNotHTLC          = errors.New("output is not a hash time-locked contract")
+	ErrWrongSecret      = errors.New("secret does not match the contract hash")
+	ErrNoContractWallet = errors.New("no local wallet can redeem the contract")
+	ErrSecretNotFound   = errors.New("contract has not been claimed on the chain")
+)
+
+// NewHTLCTransaction locks amount from a wallet in a hash time-locked
+// contract, output 0 of the returned transaction.
+func NewHTLCTransaction(from string, contract script.HTLC, amount, fee int, utxoset *UTXOSet) (*transaction.Transaction, error) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := wallets.SigningWallet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	htlc := transaction.TXOutput{ScriptPubKey: script.HashTimeLock(contract), Value: amount}
+	tx, _, err := newTransaction(from, []transaction.TXOutput{htlc}, fee, nil, utxoset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = utxoset.Blockchain.SignTransaction(tx, wallet.PrivateKey, transaction.SigHashAll); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// NewHTLCRedeemTransaction spends a contract output to an address. With a
+// secret the recipient claims it, without one the sender takes a refund,
+// which the chain only accepts once the contract lock time has passed.
+func NewHTLCRedeemTransaction(txid []byte, vout int, secret []byte, to string, fee int, utxoset *UTXOSet) (*transaction.Transaction, error) {
+	prevOut, err := utxoset.FindOutput(txid, vout)
+	if err != nil {
+		return nil, err
+	}
+
+	contract, ok := script.ExtractHashTimeLock(prevOut.ScriptPubKey)
+	if !ok {
+		return nil, ErrNotHTLC
+	}
+
+	if fee >= prevOut.Value {
+		return nil, fmt.Errorf("fee of %d leaves nothing of the %d locked", fee, prevOut.Value)
+	}
+
+	redeemer := contract.SenderHash
+	if secret != nil {
+		if !bytes.Equal(script.SecretHash(secret), contract.SecretHash) {
+			return nil, ErrWrongSecret
+		}
+		redeemer = contract.RecipientHash
+	}
+
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := wallets.SigningWallet(string(common.EncodeAddress(common.PubKeyHashVersion, redeemer)))
+	if errors.Is(err, wallet.ErrNoWallet) {
+		return nil, ErrNoContractWallet
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := transaction.NewTXOutput(prevOut.Value-fee, to)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: txid, Vout: vout}},
+		Vout: []transaction.TXOutput{*output},
+	}
+	if secret == nil {
+		tx.LockTime = contract.LockTime
+	}
+
+	if tx.ID, err = tx.ComputeID(); err != nil {
+		return nil, err
+	}
+
+	prevOuts := map[string]transaction.TXOutput{transaction.OutpointKey(txid, vout): *prevOut}
+	sig, err := tx.SignInput(0, w.PrivateKey, prevOuts, transaction.SigHashAll)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret != nil {
+		tx.Vin[0].Witness = script.UnlockHTLCClaim(sig, w.PublicKey, secret)
+	} else {
+		tx.Vin[0].Witness = script.UnlockHTLCRefund(sig, w.PublicKey)
+	}
+
+	return &tx, nil
+}
+
+// FindHTLCSecret looks through the chain for the claim of a contract output
+// and returns the secret it revealed.
+func (bc *Blockchain) FindHTLCSecret(txid []byte, vout int) ([]byte, error) {
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, vin := range tx.Vin {
+				if !bytes.Equal(vin.Txid, txid) || vin.Vout != vout {
+					continue
+				}
+
+				// A claim pushes <sig> <pubKey> <secret> 1, a refund ends in 0.
+				pushes, err := vin.UnlockingData()
+				if err != nil || len(pushes) != 4 {
+					return nil, ErrSecretNotFound
+				}
+				return pushes[2], nil
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	return nil, ErrSecretNotFound
+}
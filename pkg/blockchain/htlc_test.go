@@ -0,0 +1,165 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+// newTestWalletFile writes a wallet file with the keys of a contract's sender
+// and recipient, which the HTLC functions sign with.
+func newTestWalletFile(t *testing.T) (sender, recipient wallet.Wallet) {
+	t.Helper()
+
+	if err := os.Remove("resources/wallet.dat"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wallets.NewSeed(12); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []wallet.Wallet
+	for i := 0; i < 2; i++ {
+		address, err := wallets.CreateWallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := wallets.GetWallet(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, w)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	return keys[0], keys[1]
+}
+
+// newTestContract funds the sender and mines a contract locking 5 of its
+// coins until height lockTime, output 0 of the returned transaction.
+func newTestContract(t *testing.T, secret []byte, lockTime int64) (*Blockchain, *transaction.Transaction, *UTXOSet) {
+	t.Helper()
+
+	bc, w := newTestChain(t)
+	sender, recipient := newTestWalletFile(t)
+	_, miner := newTestWallet(t)
+	utxoset := &UTXOSet{Blockchain: bc}
+
+	mineTestBlock(t, bc, miner, newTestPayment(t, bc, w, string(sender.GetAddress()), 8, 1))
+
+	contract := script.HTLC{
+		SecretHash:    script.SecretHash(secret),
+		RecipientHash: common.HashPubKey(recipient.PublicKey),
+		SenderHash:    common.HashPubKey(sender.PublicKey),
+		LockTime:      lockTime,
+	}
+	htlc, err := NewHTLCTransaction(string(sender.GetAddress()), contract, 5, 1, utxoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (Mempool{Blockchain: bc}).Add(htlc); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc, miner, htlc)
+
+	return bc, htlc, utxoset
+}
+
+func TestHTLCClaim(t *testing.T) {
+	secret := []byte("the secret")
+	bc, htlc, utxoset := newTestContract(t, secret, 100)
+	_, to := newTestWallet(t)
+
+	if _, err := bc.FindHTLCSecret(htlc.ID, 0); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("FindHTLCSecret before the claim = %v, want %v", err, ErrSecretNotFound)
+	}
+	if _, err := NewHTLCRedeemTransaction(htlc.ID, 0, []byte("a guess"), to, 1, utxoset); !errors.Is(err, ErrWrongSecret) {
+		t.Errorf("NewHTLCRedeemTransaction with a wrong secret = %v, want %v", err, ErrWrongSecret)
+	}
+	if _, err := NewHTLCRedeemTransaction(htlc.ID, 1, secret, to, 1, utxoset); !errors.Is(err, ErrNotHTLC) {
+		t.Errorf("NewHTLCRedeemTransaction of the change = %v, want %v", err, ErrNotHTLC)
+	}
+
+	claim, err := NewHTLCRedeemTransaction(htlc.ID, 0, secret, to, 1, utxoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := claim.ComputeID(); err != nil || !bytes.Equal(claim.ID, id) {
+		t.Errorf("claim ID %x, want %x", claim.ID, id)
+	}
+	if err = (Mempool{Blockchain: bc}).Add(claim); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc, to, claim)
+
+	found, err := bc.FindHTLCSecret(htlc.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(found, secret) {
+		t.Errorf("FindHTLCSecret = %q, want %q", found, secret)
+	}
+}
+
+func TestHTLCRefund(t *testing.T) {
+	secret := []byte("the secret")
+	// The contract is mined at height 2.
+	bc, htlc, utxoset := newTestContract(t, secret, 4)
+	_, to := newTestWallet(t)
+	mempool := Mempool{Blockchain: bc}
+
+	refund, err := NewHTLCRedeemTransaction(htlc.ID, 0, nil, to, 1, utxoset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, err := refund.ComputeID(); err != nil || !bytes.Equal(refund.ID, id) {
+		t.Errorf("refund ID %x, want %x", refund.ID, id)
+	}
+	if refund.LockTime != 4 {
+		t.Errorf("refund locked until %d, want 4", refund.LockTime)
+	}
+
+	for height := 3; height <= 4; height++ {
+		if err = mempool.Add(refund); !errors.Is(err, ErrNonFinalTx) {
+			t.Errorf("Add at height %d = %v, want %v", height, err, ErrNonFinalTx)
+		}
+		mineTestBlock(t, bc, to)
+	}
+	if err = mempool.Add(refund); err != nil {
+		t.Fatal(err)
+	}
+	mineTestBlock(t, bc, to, refund)
+
+	if _, err = bc.FindHTLCSecret(htlc.ID, 0); !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("FindHTLCSecret after the refund = %v, want %v", err, ErrSecretNotFound)
+	}
+}
+
+func TestHTLCNoContractWallet(t *testing.T) {
+	secret := []byte("the secret")
+	_, htlc, utxoset := newTestContract(t, secret, 100)
+	_, to := newTestWallet(t)
+
+	// Another wallet file holds neither key of the contract.
+	newTestWalletFile(t)
+
+	for _, s := range [][]byte{secret, nil} {
+		if _, err := NewHTLCRedeemTransaction(htlc.ID, 0, s, to, 1, utxoset); !errors.Is(err, ErrNoContractWallet) {
+			t.Errorf("NewHTLCRedeemTransaction = %v, want %v", err, ErrNoContractWallet)
+		}
+	}
+}
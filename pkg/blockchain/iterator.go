@@ -16,9 +16,7 @@ func (bci *BlockchainIterator) Next() *Block {
 	var err error
 
 	err = bci.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(blocksBucket))
-		encodedBlock := bucket.Get(bci.currentHash)
-		block, err = DeserializeBlock(encodedBlock)
+		block, err = getStoredBlock(tx, bci.currentHash)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,455 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+const mempoolBucket = "mempool"
+
+var (
+	ErrEmptyMempool       = errors.New("mempool is empty")
+	ErrTxInMempool        = errors.New("transaction is already in the mempool")
+	ErrMempoolConflict    = errors.New("transaction spends an output already spent in the mempool")
+	ErrInvalidTransaction = errors.New("transaction is invalid")
+	ErrReplacementFee     = errors.New("replacement does not pay more than the transactions it replaces")
+)
+
+// Mempool stores unconfirmed transactions next to the chain until a miner
+// puts them into a block. Every transaction in it spends outputs of the
+// UTXO set that no other mempool transaction spends. A transaction spending
+// the same outputs as replaceable ones replaces them if it pays a higher fee
+// than all of them together.
+type Mempool struct {
+	Blockchain *Blockchain
+}
+
+// mempoolSpentOutputs returns the outpoints spent by the mempool transactions
+// with the ID of the transaction spending them.
+func mempoolSpentOutputs(tx *bolt.Tx) (map[string][]byte, error) {
+	spent := make(map[string][]byte)
+
+	b := tx.Bucket([]byte(mempoolBucket))
+	if b == nil {
+		return spent, nil
+	}
+
+	err := b.ForEach(func(k, v []byte) error {
+		mtx, err := transaction.DeserializeTransaction(v)
+		if err != nil {
+			return err
+		}
+
+		for _, vin := range mtx.Vin {
+			spent[transaction.OutpointKey(vin.Txid, vin.Vout)] = mtx.ID
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return spent, nil
+}
+
+// dropLegacyMempool removes the mempool transactions stored with gob by
+// earlier versions, which are no longer accepted. Their senders can submit
+// them again, canonically encoded.
+func dropLegacyMempool(tx *bolt.Tx) error {
+	b := tx.Bucket([]byte(mempoolBucket))
+	if b == nil {
+		return nil
+	}
+
+	// Collect first, bolt cursors do not survive writes to their bucket.
+	var legacy [][]byte
+	err := b.ForEach(func(k, v []byte) error {
+		if common.IsLegacyEncoding(v) {
+			legacy = append(legacy, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, k := range legacy {
+		if err = b.Delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Add validates a transaction against the UTXO set and the mempool and stores it.
+func (m Mempool) Add(tx *transaction.Transaction) error {
+	if tx.IsCoinbase() {
+		return fmt.Errorf("%w: coinbase transactions are only valid in blocks", ErrInvalidTransaction)
+	}
+
+	if err := checkTransaction(tx); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTransaction, err)
+	}
+
+	id, err := tx.ComputeID()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(id, tx.ID) {
+		return fmt.Errorf("%w: %v", ErrInvalidTransaction, ruleError(ErrBadTxID, "transaction %x hashes to %x", tx.ID, id))
+	}
+
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return m.Blockchain.DB.Update(func(btx *bolt.Tx) error {
+		b, err := btx.CreateBucketIfNotExists([]byte(mempoolBucket))
+		if err != nil {
+			return err
+		}
+
+		if b.Get(tx.ID) != nil {
+			return ErrTxInMempool
+		}
+
+		pending, err := mempoolSpentOutputs(btx)
+		if err != nil {
+			return err
+		}
+
+		// Only transactions the next block may include are accepted.
+		tip, err := getStoredBlock(btx, m.Blockchain.tip)
+		if err != nil {
+			return err
+		}
+		medianTime, err := medianTimePast(btx, tip)
+		if err != nil {
+			return err
+		}
+		if !tx.IsFinal(tip.Height+1, medianTime) {
+			return fmt.Errorf("%w: locked until %d", ErrNonFinalTx, tx.LockTime)
+		}
+
+		inputs := 0
+		prevOuts := make(map[string]transaction.TXOutput)
+		spent := make(map[string]bool)
+		conflicts := make(map[string][]byte)
+		for _, vin := range tx.Vin {
+			op := transaction.OutpointKey(vin.Txid, vin.Vout)
+			if spent[op] {
+				return fmt.Errorf("%w: output %s is spent twice", ErrInvalidTransaction, op)
+			}
+			spent[op] = true
+
+			if conflict := pending[op]; conflict != nil {
+				conflicts[string(conflict)] = conflict
+			}
+
+			out, err := findUnspentOutput(btx, vin.Txid, vin.Vout)
+			if err != nil {
+				return fmt.Errorf("%w: %s", err, op)
+			}
+			var ok bool
+			if inputs, ok = addValue(inputs, out.Value); !ok {
+				return fmt.Errorf("%w: inputs exceed the supply of %d", ErrInvalidTransaction, transaction.MaxSupply)
+			}
+			prevOuts[op] = *out
+		}
+
+		verified, err := tx.Verify(prevOuts)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return fmt.Errorf("%w: signature verification failed", ErrInvalidTransaction)
+		}
+
+		outputs := 0
+		for _, out := range tx.Vout {
+			var ok bool
+			if outputs, ok = addValue(outputs, out.Value); !ok {
+				return fmt.Errorf("%w: outputs exceed the supply of %d", ErrInvalidTransaction, transaction.MaxSupply)
+			}
+		}
+
+		if outputs > inputs {
+			return fmt.Errorf("%w: outputs of %d exceed inputs of %d", ErrInvalidTransaction, outputs, inputs)
+		}
+
+		if err = replace(btx, b, conflicts, inputs-outputs); err != nil {
+			return err
+		}
+
+		return b.Put(tx.ID, serialized)
+	})
+}
+
+// replace removes the mempool transactions a new one paying fee conflicts
+// with, provided they all opted in to replacement and it pays more than they
+// do together.
+func replace(btx *bolt.Tx, b *bolt.Bucket, conflicts map[string][]byte, fee int) error {
+	replacedFees := 0
+	for _, txid := range conflicts {
+		conflict, err := transaction.DeserializeTransaction(b.Get(txid))
+		if err != nil {
+			return err
+		}
+
+		if !conflict.IsReplaceable() {
+			return fmt.Errorf("%w: %x is not replaceable", ErrMempoolConflict, txid)
+		}
+
+		conflictFee, err := transactionFee(btx, &conflict)
+		if err != nil {
+			return err
+		}
+		replacedFees += conflictFee
+	}
+
+	if len(conflicts) > 0 && fee <= replacedFees {
+		return fmt.Errorf("%w: fee of %d, replaced fees of %d", ErrReplacementFee, fee, replacedFees)
+	}
+
+	for _, txid := range conflicts {
+		if err := b.Delete(txid); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns the mempool transaction with the given ID.
+func (m Mempool) Get(txid []byte) (*transaction.Transaction, error) {
+	var found *transaction.Transaction
+
+	err := m.Blockchain.DB.View(func(btx *bolt.Tx) error {
+		b := btx.Bucket([]byte(mempoolBucket))
+		if b == nil {
+			return nil
+		}
+
+		data := b.Get(txid)
+		if data == nil {
+			return nil
+		}
+
+		tx, err := transaction.DeserializeTransaction(data)
+		if err != nil {
+			return err
+		}
+
+		found = &tx
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("transaction %s is not in the mempool", hex.EncodeToString(txid))
+	}
+
+	return found, nil
+}
+
+// Take returns up to max transactions, highest fee first, without removing
+// them. All of them are returned when max is not positive.
+func (m Mempool) Take(max int) ([]*transaction.Transaction, error) {
+	var txs []*transaction.Transaction
+	fees := make(map[*transaction.Transaction]int)
+
+	err := m.Blockchain.DB.View(func(btx *bolt.Tx) error {
+		b := btx.Bucket([]byte(mempoolBucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			tx, err := transaction.DeserializeTransaction(v)
+			if err != nil {
+				return err
+			}
+
+			fee, err := transactionFee(btx, &tx)
+			if err != nil {
+				return err
+			}
+
+			txs = append(txs, &tx)
+			fees[&tx] = fee
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool { return fees[txs[i]] > fees[txs[j]] })
+
+	if max > 0 && len(txs) > max {
+		txs = txs[:max]
+	}
+
+	return txs, nil
+}
+
+// Fee returns what the inputs of a transaction spending the UTXO set leave
+// over its outputs, the miner collects it.
+func (m Mempool) Fee(tx *transaction.Transaction) (int, error) {
+	fee := 0
+
+	err := m.Blockchain.DB.View(func(btx *bolt.Tx) error {
+		var err error
+		fee, err = transactionFee(btx, tx)
+		return err
+	})
+
+	return fee, err
+}
+
+func transactionFee(btx *bolt.Tx, tx *transaction.Transaction) (int, error) {
+	if tx.IsCoinbase() {
+		return 0, nil
+	}
+
+	fee := 0
+	for _, vin := range tx.Vin {
+		out, err := findUnspentOutput(btx, vin.Txid, vin.Vout)
+		if err != nil {
+			return 0, err
+		}
+
+		fee += out.Value
+	}
+
+	for _, out := range tx.Vout {
+		fee -= out.Value
+	}
+
+	return fee, nil
+}
+
+// Count returns the number of transactions in the mempool.
+func (m Mempool) Count() (int, error) {
+	count := 0
+
+	err := m.Blockchain.DB.View(func(btx *bolt.Tx) error {
+		b := btx.Bucket([]byte(mempoolBucket))
+		if b != nil {
+			count = b.Stats().KeyN
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Prune drops the transactions whose inputs are no longer in the UTXO set,
+// either because they were mined or because a block spent them first. It
+// has to run after the UTXO set has been updated.
+func (m Mempool) Prune() error {
+	return m.Blockchain.DB.Update(func(btx *bolt.Tx) error {
+		b := btx.Bucket([]byte(mempoolBucket))
+		if b == nil {
+			return nil
+		}
+
+		var stale [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			tx, err := transaction.DeserializeTransaction(v)
+			if err != nil {
+				return err
+			}
+
+			for _, vin := range tx.Vin {
+				_, err := findUnspentOutput(btx, vin.Txid, vin.Vout)
+				if errors.Is(err, ErrOutputNotFound) {
+					stale = append(stale, k)
+					break
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range stale {
+			if err = b.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Mine puts up to maxTxs mempool transactions (all when not positive) into a
+// new block rewarding minerAddress with the subsidy and their fees.
+func (m Mempool) Mine(minerAddress string, maxTxs int) (*Block, error) {
+	block, err := m.BlockTemplate(minerAddress, maxTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = block.Mine(); err != nil {
+		return nil, err
+	}
+
+	if _, err = m.Blockchain.AddBlock(block); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+// BlockTemplate is the block Mine mines, before its proof of work.
+func (m Mempool) BlockTemplate(minerAddress string, maxTxs int) (*Block, error) {
+	txs, err := m.Take(maxTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(txs) == 0 {
+		return nil, ErrEmptyMempool
+	}
+
+	height, err := m.Blockchain.GetBestHeight()
+	if err != nil {
+		return nil, err
+	}
+
+	fees := 0
+	for _, tx := range txs {
+		fee, err := m.Fee(tx)
+		if err != nil {
+			return nil, err
+		}
+
+		fees += fee
+	}
+
+	cbtx, err := transaction.NewCoinbaseTX(minerAddress, "", height+1, fees)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Blockchain.NewBlockTemplate(append([]*transaction.Transaction{cbtx}, txs...))
+}
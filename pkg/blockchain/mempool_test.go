@@ -0,0 +1,137 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+)
+
+// genesisCoinbase returns the coinbase of the genesis block of bc, paying
+// the wallet of newTestChain.
+func genesisCoinbase(t *testing.T, bc *Blockchain) *transaction.Transaction {
+	t.Helper()
+
+	hashes := bc.GetBlockHashes()
+	genesis, err := bc.GetBlock(hashes[len(hashes)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return genesis.Transactions[0]
+}
+
+func TestMempoolConflict(t *testing.T) {
+	bc, w := newTestChain(t)
+	_, bob := newTestWallet(t)
+	_, carol := newTestWallet(t)
+	mempool := Mempool{Blockchain: bc}
+	coinbase := genesisCoinbase(t, bc)
+
+	first := spendTestOutput(t, coinbase, 0, w, bob, 9)
+	if err := mempool.Add(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := mempool.Add(first); !errors.Is(err, ErrTxInMempool) {
+		t.Errorf("Add of the same transaction = %v, want %v", err, ErrTxInMempool)
+	}
+
+	// Without opting in to replacement, not even a higher fee replaces it.
+	for _, amount := range []int{9, 5} {
+		double := spendTestOutput(t, coinbase, 0, w, carol, amount)
+		if err := mempool.Add(double); !errors.Is(err, ErrMempoolConflict) {
+			t.Errorf("Add of a double spend paying %d = %v, want %v", amount, err, ErrMempoolConflict)
+		}
+	}
+
+	if _, err := mempool.Get(first.ID); err != nil {
+		t.Errorf("first spend dropped: %v", err)
+	}
+	if count, err := mempool.Count(); err != nil || count != 1 {
+		t.Errorf("Count = %d, %v, want 1", count, err)
+	}
+}
+
+func TestMempoolTake(t *testing.T) {
+	bc, w := newTestChain(t)
+	_, to := newTestWallet(t)
+	mempool := Mempool{Blockchain: bc}
+
+	// A coin per payment, all worth the subsidy.
+	mineTestBlock(t, bc, string(w.GetAddress()))
+	mineTestBlock(t, bc, string(w.GetAddress()))
+
+	var txs []*transaction.Transaction
+	for _, fee := range []int{1, 3, 2} {
+		tx := newTestPayment(t, bc, w, to, 3, fee)
+		if err := mempool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+
+	tests := []struct {
+		max  int
+		want []*transaction.Transaction
+	}{
+		{max: 0, want: []*transaction.Transaction{txs[1], txs[2], txs[0]}},
+		{max: 2, want: []*transaction.Transaction{txs[1], txs[2]}},
+		{max: 5, want: []*transaction.Transaction{txs[1], txs[2], txs[0]}},
+	}
+
+	for _, tt := range tests {
+		got, err := mempool.Take(tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("Take(%d) returned %d transactions, want %d", tt.max, len(got), len(tt.want))
+		}
+		for i := range got {
+			if !bytes.Equal(got[i].ID, tt.want[i].ID) {
+				t.Errorf("Take(%d)[%d] = %x, want %x", tt.max, i, got[i].ID, tt.want[i].ID)
+			}
+		}
+	}
+
+	if count, err := mempool.Count(); err != nil || count != 3 {
+		t.Errorf("Count after Take = %d, %v, want 3", count, err)
+	}
+}
+
+func TestMempoolPrune(t *testing.T) {
+	bc, w := newTestChain(t)
+	_, bob := newTestWallet(t)
+	_, carol := newTestWallet(t)
+	_, miner := newTestWallet(t)
+	mempool := Mempool{Blockchain: bc}
+
+	block := mineTestBlock(t, bc, string(w.GetAddress()))
+	coinbase := genesisCoinbase(t, bc)
+
+	// The mempool spends both coins, a block spends the first one before
+	// its mempool transaction is mined.
+	outbid := spendTestOutput(t, coinbase, 0, w, bob, 9)
+	kept := spendTestOutput(t, block.Transactions[0], 0, w, bob, 9)
+	for _, tx := range []*transaction.Transaction{outbid, kept} {
+		if err := mempool.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mineTestBlock(t, bc, miner, spendTestOutput(t, coinbase, 0, w, carol, 9))
+	if _, err := mempool.Get(outbid.ID); err == nil {
+		t.Error("transaction whose coin a block spent is still in the mempool")
+	}
+	if _, err := mempool.Get(kept.ID); err != nil {
+		t.Errorf("unrelated transaction dropped: %v", err)
+	}
+
+	if _, err := mempool.Mine(miner, 0); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := mempool.Count(); err != nil || count != 0 {
+		t.Errorf("Count after mining = %d, %v, want 0", count, err)
+	}
+}
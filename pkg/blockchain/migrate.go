@@ -0,0 +1,92 @@
+package blockchain
+
+import (
+	"bytes"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+// Migrate rewrites the blocks, unspent outputs and undo data stored with gob
+// in the canonical encoding and returns how many records changed. Records
+// are read in either encoding, so migrating is optional and can be repeated.
+// Transactions inside migrated blocks keep their gob encoding, as their IDs,
+// signatures and the block merkle roots were computed over it. Mempool
+// transactions stored with gob are dropped when the database is opened, see
+// dropLegacyMempool. Blocks of the first versions, whose hash was not
+// computed over the header, stay in gob.
+func (bc *Blockchain) Migrate() (int, error) {
+	migrated := 0
+
+	err := bc.DB.Update(func(tx *bolt.Tx) error {
+		// A nil encoding leaves the record as it is.
+		reencoders := map[string]func(k, data []byte) ([]byte, error){
+			blocksBucket: func(k, data []byte) ([]byte, error) {
+				block, err := getStoredBlock(tx, k)
+				if err != nil {
+					return nil, err
+				}
+				if !bytes.Equal(block.BlockHeader.Hash(), k) {
+					return nil, nil
+				}
+				return block.Serialize()
+			},
+			utxoBucket: func(k, data []byte) ([]byte, error) {
+				outs, err := transaction.DeserializeOutputs(data)
+				if err != nil {
+					return nil, err
+				}
+				return outs.Serialize()
+			},
+			undoBucket: func(k, data []byte) ([]byte, error) {
+				spent, err := deserializeUndo(data)
+				if err != nil {
+					return nil, err
+				}
+				return serializeUndo(spent), nil
+			},
+		}
+
+		for bucket, reencode := range reencoders {
+			b := tx.Bucket([]byte(bucket))
+			if b == nil {
+				continue
+			}
+
+			// Collect first, bolt cursors do not survive writes to their bucket.
+			legacy := make(map[string][]byte)
+			err := b.ForEach(func(k, v []byte) error {
+				if bucket == blocksBucket && string(k) == "l" {
+					return nil
+				}
+				if common.IsLegacyEncoding(v) {
+					legacy[string(k)] = v
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for k, v := range legacy {
+				encoded, err := reencode([]byte(k), v)
+				if err != nil {
+					return err
+				}
+				if encoded == nil {
+					continue
+				}
+
+				if err = b.Put([]byte(k), encoded); err != nil {
+					return err
+				}
+				migrated++
+			}
+		}
+
+		return nil
+	})
+
+	return migrated, err
+}
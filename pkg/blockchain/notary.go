@@ -0,0 +1,72 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+var ErrDataNotFound = errors.New("no data output carries the data")
+
+// NewDataTransaction anchors data in the chain with a null data output paid
+// for by a wallet.
+func NewDataTransaction(from string, data []byte, fee int, utxoset *UTXOSet) (*transaction.Transaction, error) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := wallets.SigningWallet(from)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := transaction.NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, _, err := newTransaction(from, []transaction.TXOutput{*output}, fee, nil, utxoset)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = utxoset.Blockchain.SignTransaction(tx, wallet.PrivateKey, transaction.SigHashAll); err != nil {
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// FindDataOutput returns the oldest block and transaction with a null data
+// output carrying data.
+func (bc *Blockchain) FindDataOutput(data []byte) (*Block, *transaction.Transaction, error) {
+	var foundBlock *Block
+	var foundTx *transaction.Transaction
+	bci := bc.Iterator()
+
+	for {
+		block := bci.Next()
+
+		for _, tx := range block.Transactions {
+			for _, out := range tx.Vout {
+				if carried, ok := script.ExtractNullData(out.ScriptPubKey); ok && bytes.Equal(carried, data) {
+					foundBlock, foundTx = block, tx
+				}
+			}
+		}
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+	}
+
+	if foundBlock == nil {
+		return nil, nil, ErrDataNotFound
+	}
+
+	return foundBlock, foundTx, nil
+}
@@ -3,6 +3,8 @@ package blockchain
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -13,57 +15,52 @@ type ProofOfWork struct {
 	target *big.Int
 }
 
-const targetBits = 24
 const maxNonce = math.MaxInt64
 
+var ErrNonceExhausted = errors.New("no nonce meets the target")
+
+// NewProofOfWork prepares the work for the target recorded in the block bits.
 func NewProofOfWork(b *Block) *ProofOfWork {
-	target := big.NewInt(1)
-	target.Lsh(target, uint(256-targetBits))
+	target := CompactToBig(b.Bits)
 
 	pow := &ProofOfWork{b, target}
 
 	return pow
 }
 
-func (pow *ProofOfWork) prepareData(nonce int) ([]byte, error) {
-	transactionHash, err := pow.block.HashTransaction()
-	if err != nil {
-		return nil, err
-	}
+// Work returns the expected number of hashes needed to meet the target,
+// 2^256 / (target+1).
+func (pow *ProofOfWork) Work() *big.Int {
+	denominator := new(big.Int).Add(pow.target, big.NewInt(1))
+	numerator := new(big.Int).Lsh(big.NewInt(1), 256)
 
-	data := bytes.Join(
-		[][]byte{
-			pow.block.PrevBlockHash,
-			transactionHash,
-			[]byte(fmt.Sprintf("%x", pow.block.Timestamp)),
-			[]byte(fmt.Sprintf("%x", int64(targetBits))),
-			[]byte(fmt.Sprintf("%x", int64(nonce))),
-		},
-		[]byte{},
-	)
+	return numerator.Div(numerator, denominator)
+}
 
-	return data, nil
+// prepareData serializes the header with the given nonce, the rest of the
+// header is fixed while mining so only the nonce bytes change.
+func (pow *ProofOfWork) prepareData(nonce int64) []byte {
+	header := pow.block.BlockHeader
+	header.Nonce = nonce
 
+	return header.Serialize()
 }
 
-func (pow *ProofOfWork) Run() (int, []byte, error) {
+func (pow *ProofOfWork) Run() (int64, []byte, error) {
 	var hashInt big.Int
 	var hash [32]byte
-	nonce := 0
+	var nonce int64
+
+	data := pow.prepareData(nonce)
 
 	fmt.Printf("Mining a new block")
 	for nonce < maxNonce {
-		data, err := pow.prepareData(nonce)
-		if err != nil {
-			return 0, nil, err
-		}
+		binary.BigEndian.PutUint64(data[nonceOffset:], uint64(nonce))
 
 		hash = sha256.Sum256(data)
-		// fmt.Printf("\r%x", hash)
 		hashInt.SetBytes(hash[:])
 
 		/**
-		for testing only, actual mining should be hashInt.Cmp(pow.target) == -1
 		Why This Comparison?
 			Proof of Work:
 			The comparison ensures that the miner has done a sufficient amount of computational work.
@@ -71,32 +68,41 @@ func (pow *ProofOfWork) Run() (int, []byte, error) {
 
 			Difficulty Adjustment:
 			The target is adjusted based on the network's total computational power to maintain a consistent
-			block generation time.
+			block generation time, see difficulty.go.
 
 			Security:
 			This process secures the network by making it computationally expensive to alter the blockchain.
 			An attacker would need to redo the proof of work for all subsequent blocks to change a block's data.
 		*/
-		if hashInt.Cmp(pow.target) > -1 {
+		if hashInt.Cmp(pow.target) == -1 {
 			break
 		}
 
 		nonce++
 	}
 
+	if nonce == maxNonce {
+		return 0, nil, ErrNonceExhausted
+	}
+
 	fmt.Print("\n\n")
 	return nonce, hash[:], nil
 }
 
-func (pow *ProofOfWork) Validate() (bool, error) {
+// Validate checks that the block carries the bits the chain required at its
+// height and that its hash meets the target they encode.
+func (pow *ProofOfWork) Validate(requiredBits uint32) (bool, error) {
 	var hashInt big.Int
 
-	data, err := pow.prepareData(pow.block.Nonce)
-	if err != nil {
-		return false, err
+	if pow.block.Bits != requiredBits {
+		return false, nil
+	}
+
+	hash := sha256.Sum256(pow.prepareData(pow.block.Nonce))
+	if !bytes.Equal(hash[:], pow.block.Hash) {
+		return false, nil
 	}
 
-	hash := sha256.Sum256(data)
 	hashInt.SetBytes(hash[:])
 
 	return hashInt.Cmp(pow.target) == -1, nil
@@ -0,0 +1,79 @@
+package blockchain
+
+import (
+	"bytes"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/boltdb/bolt"
+)
+
+// getStoredBlock reads a block of the database, completing the ones stored
+// with gob from the block index, see fillLegacyBlock.
+func getStoredBlock(tx *bolt.Tx, hash []byte) (*Block, error) {
+	data := tx.Bucket([]byte(blocksBucket)).Get(hash)
+	if data == nil {
+		return nil, ErrBlockNotFound
+	}
+
+	block, err := deserializeStoredBlock(data)
+	if err != nil || !common.IsLegacyEncoding(data) {
+		return block, err
+	}
+
+	index, err := getBlockIndex(tx, hash)
+	if err != nil {
+		return nil, err
+	}
+	fillLegacyBlock(block, index.Height)
+
+	return block, nil
+}
+
+// reorganize moves the tip to newTip: blocks of the current chain back to the
+// fork point are disconnected from the UTXO set, then the branch leading to
+// newTip is connected. It returns the disconnected blocks, tip first.
+func reorganize(tx *bolt.Tx, newTip *Block) ([]*Block, error) {
+	b := tx.Bucket([]byte(blocksBucket))
+
+	oldTip, err := getStoredBlock(tx, b.Get([]byte("l")))
+	if err != nil {
+		return nil, err
+	}
+
+	var oldBranch, newBranch []*Block
+	oldBlock, newBlock := oldTip, newTip
+
+	for !bytes.Equal(oldBlock.Hash, newBlock.Hash) {
+		if newBlock.Height >= oldBlock.Height {
+			newBranch = append(newBranch, newBlock)
+			newBlock, err = getStoredBlock(tx, newBlock.PrevBlockHash)
+		} else {
+			oldBranch = append(oldBranch, oldBlock)
+			oldBlock, err = getStoredBlock(tx, oldBlock.PrevBlockHash)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, block := range oldBranch {
+		if err = disconnectUTXO(tx, block); err != nil {
+			return nil, err
+		}
+	}
+
+	// Transactions of the new branch are checked as their block becomes the
+	// tip, an invalid one aborts the whole reorganization.
+	for i := len(newBranch) - 1; i >= 0; i-- {
+		if err = checkBlockTransactions(tx, newBranch[i]); err != nil {
+			return nil, err
+		}
+
+		if err = connectUTXO(tx, newBranch[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return oldBranch, b.Put([]byte("l"), newTip.Hash)
+}
@@ -0,0 +1,142 @@
+package blockchain
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+func TestAddBlockReorganizesToMostWork(t *testing.T) {
+	tests := []struct {
+		name string
+		// blocks mined on each branch from the genesis block, ours first
+		// spending the genesis coinbase.
+		ours, theirs int
+		reorganized  bool
+	}{
+		{name: "branch with more work becomes the tip", ours: 1, theirs: 2, reorganized: true},
+		{name: "longer branch replaces several blocks", ours: 2, theirs: 4, reorganized: true},
+		{name: "branch with less work is kept aside", ours: 3, theirs: 2},
+		{name: "branch with as much work is kept aside", ours: 2, theirs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, w := newTestChain(t)
+			other := copyTestChain(t, bc)
+			_, to := newTestWallet(t)
+			_, miner := newTestWallet(t)
+
+			tx := newTestPayment(t, bc, w, to, 3, 1)
+			mineTestBlock(t, bc, miner, tx)
+			for i := 1; i < tt.ours; i++ {
+				mineTestBlock(t, bc, miner)
+			}
+			ourTip := bc.GetBlockHashes()[0]
+			ourUTXO := bucketContent(t, bc, utxoBucket)
+
+			var branch []*Block
+			for i := 0; i < tt.theirs; i++ {
+				branch = append(branch, mineTestBlock(t, other, miner))
+			}
+
+			for i, block := range branch {
+				tipChanged, err := bc.AddBlock(block)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := i >= tt.ours; tipChanged != want {
+					t.Errorf("block %d changed the tip: %v, want %v", i, tipChanged, want)
+				}
+			}
+
+			_, err := (Mempool{Blockchain: bc}).Get(tx.ID)
+			if !tt.reorganized {
+				if tip := bc.GetBlockHashes()[0]; !bytes.Equal(tip, ourTip) {
+					t.Errorf("tip moved to %x", tip)
+				}
+				if !reflect.DeepEqual(bucketContent(t, bc, utxoBucket), ourUTXO) {
+					t.Error("UTXO set changed without a reorganization")
+				}
+				if err == nil {
+					t.Error("mined transaction is in the mempool")
+				}
+				return
+			}
+
+			if tip, want := bc.GetBlockHashes()[0], other.GetBlockHashes()[0]; !bytes.Equal(tip, want) {
+				t.Errorf("tip is %x, want %x", tip, want)
+			}
+			for _, bucket := range []string{utxoBucket, undoBucket} {
+				if !reflect.DeepEqual(bucketContent(t, bc, bucket), bucketContent(t, other, bucket)) {
+					t.Errorf("%s differs from the one of the chain connected directly", bucket)
+				}
+			}
+			if err != nil {
+				t.Errorf("transaction of a disconnected block is not back in the mempool: %v", err)
+			}
+		})
+	}
+}
+
+func TestRevertRestoresUTXOSet(t *testing.T) {
+	tests := []struct {
+		name string
+		txs  func(t *testing.T, bc *Blockchain, w *wallet.Wallet) []*transaction.Transaction
+	}{
+		{
+			name: "coinbase only",
+			txs:  func(t *testing.T, bc *Blockchain, w *wallet.Wallet) []*transaction.Transaction { return nil },
+		},
+		{
+			name: "payment with change",
+			txs: func(t *testing.T, bc *Blockchain, w *wallet.Wallet) []*transaction.Transaction {
+				_, to := newTestWallet(t)
+				return []*transaction.Transaction{newTestPayment(t, bc, w, to, 4, 1)}
+			},
+		},
+		{
+			name: "output spent in the block creating it",
+			txs: func(t *testing.T, bc *Blockchain, w *wallet.Wallet) []*transaction.Transaction {
+				middle, middleAddress := newTestWallet(t)
+				_, to := newTestWallet(t)
+
+				first := newTestPayment(t, bc, w, middleAddress, 5, 0)
+				second := spendTestOutput(t, first, 0, middle, to, 5)
+				return []*transaction.Transaction{first, second}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, w := newTestChain(t)
+			_, miner := newTestWallet(t)
+
+			before := bucketContent(t, bc, utxoBucket)
+			block := mineTestBlock(t, bc, miner, tt.txs(t, bc, w)...)
+			after := bucketContent(t, bc, utxoBucket)
+
+			utxoSet := UTXOSet{Blockchain: bc}
+			if err := utxoSet.Revert(block); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(bucketContent(t, bc, utxoBucket), before) {
+				t.Error("reverting the block does not restore the UTXO set")
+			}
+			if _, ok := bucketContent(t, bc, undoBucket)[string(block.Hash)]; ok {
+				t.Error("undo data of the reverted block is left")
+			}
+
+			if err := utxoSet.Update(block); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(bucketContent(t, bc, utxoBucket), after) {
+				t.Error("connecting the block again gives another UTXO set")
+			}
+		})
+	}
+}
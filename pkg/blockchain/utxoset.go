@@ -1,49 +1,67 @@
 package blockchain
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/hex"
 	"errors"
+	"fmt"
 
+	common "github.com/blockmandu/pkg/commons"
 	"github.com/blockmandu/pkg/transaction"
 	"github.com/boltdb/bolt"
 )
 
+const undoBucket = "undo"
+
+var (
+	ErrOutputNotFound  = errors.New("output is not found or already spent")
+	ErrMissingUndoData = errors.New("undo data of block is missing")
+)
+
 type UTXOSet struct {
 	Blockchain *Blockchain
 }
 
-func (u UTXOSet) FindSpendableOutputs(pubKeyHash []byte, amount int) (int, map[string][]int, error) {
-	unspentOutputs := make(map[string][]int)
-	accumulated := 0
-	db := u.Blockchain.DB
+// SpendableCoins returns the outputs locked with the given script, skipping
+// the ones already spent by transactions waiting in the mempool.
+func (u UTXOSet) SpendableCoins(locking []byte) ([]Coin, error) {
+	var coins []Coin
+
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		pending, err := mempoolSpentOutputs(tx)
+		if err != nil {
+			return err
+		}
 
-	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		cursor := b.Cursor()
 
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			txID := hex.EncodeToString(k)
 			outs, err := transaction.DeserializeOutputs(v)
 			if err != nil {
 				return err
 			}
 
-			for outIds, out := range outs.Outputs {
-				if out.IsLockedWithKey(pubKeyHash) && accumulated < amount {
-					accumulated += out.Value
-					unspentOutputs[txID] = append(unspentOutputs[txID], outIds)
+			for outIdx, out := range outs.Outputs {
+				if pending[transaction.OutpointKey(k, outIdx)] != nil {
+					continue
+				}
+
+				if out.IsLockedWithScript(locking) {
+					txid := append([]byte{}, k...)
+					coins = append(coins, Coin{Txid: txid, Vout: outIdx, Value: out.Value})
 				}
 			}
 		}
 
 		return nil
 	})
-
 	if err != nil {
-		return 0, nil, err
+		return nil, err
 	}
 
-	return accumulated, unspentOutputs, nil
+	return coins, nil
 }
 
 func (u UTXOSet) Reindex() error {
@@ -93,91 +111,277 @@ func (u UTXOSet) Reindex() error {
 	})
 }
 
-func (u UTXOSet) FindUTXO(PublicKey []byte) ([]transaction.TXOutput, error) {
-	var UTXOs []transaction.TXOutput
-	db := u.Blockchain.DB
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
-		cursor := b.Cursor()
+// TotalValue returns the coins held by all unspent outputs.
+func (u UTXOSet) TotalValue() (int, error) {
+	total := 0
 
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
 			outs, err := transaction.DeserializeOutputs(v)
 			if err != nil {
 				return err
 			}
 
 			for _, out := range outs.Outputs {
-				if out.IsLockedWithKey(PublicKey) {
-					UTXOs = append(UTXOs, out)
-				}
+				total += out.Value
 			}
-		}
 
-		return nil
+			return nil
+		})
 	})
 
+	return total, err
+}
+
+// FindOutput returns the unspent output vout of transaction txid.
+func (u UTXOSet) FindOutput(txid []byte, vout int) (*transaction.TXOutput, error) {
+	var output *transaction.TXOutput
+
+	err := u.Blockchain.DB.View(func(tx *bolt.Tx) error {
+		var err error
+		output, err = findUnspentOutput(tx, txid, vout)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return UTXOs, nil
+	return output, nil
+}
+
+func findUnspentOutput(tx *bolt.Tx, txid []byte, vout int) (*transaction.TXOutput, error) {
+	outsBytes := tx.Bucket([]byte(utxoBucket)).Get(txid)
+	if outsBytes == nil {
+		return nil, ErrOutputNotFound
+	}
+
+	outs, err := transaction.DeserializeOutputs(outsBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	out, ok := outs.Outputs[vout]
+	if !ok {
+		return nil, ErrOutputNotFound
+	}
+
+	return &out, nil
+}
+
+// spentOutput is an output spent by a block, kept as undo data so the output
+// can be restored when the block is disconnected.
+type spentOutput struct {
+	Txid   []byte
+	Output transaction.TXOutput
+	Vout   int
+}
+
+// serializeUndo encodes undo data canonically: version uint32, uint32 count,
+// then per spent output txid bytes | vout int32 | output.
+func serializeUndo(spent []spentOutput) []byte {
+	e := common.NewEncoder()
+	e.PutUint32(uint32(len(spent)))
+	for _, so := range spent {
+		e.PutBytes(so.Txid)
+		e.PutInt32(int32(so.Vout))
+		so.Output.Encode(e)
+	}
+
+	return e.Bytes()
+}
+
+func deserializeUndo(data []byte) ([]spentOutput, error) {
+	var spent []spentOutput
+
+	if common.IsLegacyEncoding(data) {
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&spent)
+		return spent, err
+	}
+
+	d := common.NewDecoder(data)
+	for n := d.Count(20); n > 0; n-- {
+		so := spentOutput{Txid: d.Bytes(), Vout: int(d.Int32())}
+		so.Output = transaction.DecodeOutput(d)
+		spent = append(spent, so)
+	}
+
+	return spent, d.Finish()
 }
 
 func (u UTXOSet) Update(block *Block) error {
-	db := u.Blockchain.DB
+	return u.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+		return connectUTXO(tx, block)
+	})
+}
 
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(utxoBucket))
+// Revert undoes Update for the given block, which must be the last one applied.
+func (u UTXOSet) Revert(block *Block) error {
+	return u.Blockchain.DB.Update(func(tx *bolt.Tx) error {
+		return disconnectUTXO(tx, block)
+	})
+}
+
+// connectUTXO spends the inputs and adds the outputs of a block, recording
+// the spent outputs as the block's undo data and the transactions in the
+// history index.
+func connectUTXO(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(utxoBucket))
+	var spent []spentOutput
+
+	for _, tx := range block.Transactions {
+		if !tx.IsCoinbase() {
+			for _, vin := range tx.Vin {
+				outsBytes := b.Get(vin.Txid)
+				if outsBytes == nil {
+					return fmt.Errorf("%w: %s", ErrOutputNotFound, transaction.OutpointKey(vin.Txid, vin.Vout))
+				}
+
+				updatedOuts, err := transaction.DeserializeOutputs(outsBytes)
+				if err != nil {
+					return err
+				}
+
+				out, ok := updatedOuts.Outputs[vin.Vout]
+				if !ok {
+					return fmt.Errorf("%w: %s", ErrOutputNotFound, transaction.OutpointKey(vin.Txid, vin.Vout))
+				}
+
+				spent = append(spent, spentOutput{Txid: vin.Txid, Vout: vin.Vout, Output: out})
+				delete(updatedOuts.Outputs, vin.Vout)
 
-		for _, tx := range block.Transactions {
-			if !tx.IsCoinbase() {
-				for _, vin := range tx.Vin {
-					updatedOuts := transaction.TXOutputs{}
-					outsBytes := b.Get(vin.Txid)
-					outs, err := transaction.DeserializeOutputs(outsBytes)
-					if err != nil {
-						return err
-					}
-
-					for outIdx, out := range outs.Outputs {
-						if outIdx != vin.Vout {
-							updatedOuts.Outputs = append(updatedOuts.Outputs, out)
-						}
-					}
-
-					if len(updatedOuts.Outputs) == 0 {
-						err = b.Delete(vin.Txid)
-						if err != nil {
-							return err
-						}
-					} else {
-						serialized, err := updatedOuts.Serialize()
-						if err != nil {
-							return err
-						}
-
-						err = b.Put(vin.Txid, serialized)
-						if err != nil {
-							return err
-						}
-					}
+				err = putOutputs(b, vin.Txid, updatedOuts)
+				if err != nil {
+					return err
 				}
 			}
+		}
 
-			newOutputs := transaction.TXOutputs{}
-			newOutputs.Outputs = append(newOutputs.Outputs, tx.Vout...)
+		newOutputs := transaction.NewTXOutputs()
+		for outIdx, out := range tx.Vout {
+			if out.IsUnspendable() {
+				continue
+			}
+			newOutputs.Outputs[outIdx] = out
+		}
 
-			serialized, err := newOutputs.Serialize()
-			if err != nil {
-				return err
+		err := putOutputs(b, tx.ID, newOutputs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := indexHistory(tx, block, spent); err != nil {
+		return err
+	}
+
+	undoB, err := tx.CreateBucketIfNotExists([]byte(undoBucket))
+	if err != nil {
+		return err
+	}
+
+	return undoB.Put(block.Hash, serializeUndo(spent))
+}
+
+// disconnectUTXO removes the outputs created by a block and restores the
+// ones it spent from its undo data.
+func disconnectUTXO(tx *bolt.Tx, block *Block) error {
+	b := tx.Bucket([]byte(utxoBucket))
+
+	undoB := tx.Bucket([]byte(undoBucket))
+	if undoB == nil || undoB.Get(block.Hash) == nil {
+		return fmt.Errorf("%w: %x", ErrMissingUndoData, block.Hash)
+	}
+
+	spent, err := deserializeUndo(undoB.Get(block.Hash))
+	if err != nil {
+		return err
+	}
+
+	if err = unindexHistory(tx, block, spent); err != nil {
+		return err
+	}
+
+	// Inputs were spent in block order, walking backwards restores outputs
+	// created and spent within the same block before removing them.
+	next := len(spent)
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		tx := block.Transactions[i]
+
+		err = b.Delete(tx.ID)
+		if err != nil {
+			return err
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		next -= len(tx.Vin)
+		for _, so := range spent[next : next+len(tx.Vin)] {
+			outs := transaction.NewTXOutputs()
+			if outsBytes := b.Get(so.Txid); outsBytes != nil {
+				outs, err = transaction.DeserializeOutputs(outsBytes)
+				if err != nil {
+					return err
+				}
 			}
 
-			err = b.Put(tx.ID, serialized)
+			outs.Outputs[so.Vout] = so.Output
+			err = putOutputs(b, so.Txid, outs)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		return nil
-	})
+	return undoB.Delete(block.Hash)
+}
+
+// rebuildChainstate replaces the UTXO set and undo data with the ones of the
+// main chain, connected again from the genesis block.
+func rebuildChainstate(tx *bolt.Tx) error {
+	for _, bucket := range []string{utxoBucket, undoBucket} {
+		err := tx.DeleteBucket([]byte(bucket))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return err
+		}
+	}
+
+	if _, err := tx.CreateBucket([]byte(utxoBucket)); err != nil {
+		return err
+	}
+
+	var chain []*Block
+	for hash := tx.Bucket([]byte(blocksBucket)).Get([]byte("l")); len(hash) > 0; {
+		block, err := getStoredBlock(tx, hash)
+		if err != nil {
+			return err
+		}
+
+		chain = append(chain, block)
+		hash = block.PrevBlockHash
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := connectUTXO(tx, chain[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putOutputs stores the unspent outputs of a transaction, dropping the entry
+// once all of them are spent.
+func putOutputs(b *bolt.Bucket, txid []byte, outs transaction.TXOutputs) error {
+	if len(outs.Outputs) == 0 {
+		return b.Delete(txid)
+	}
+
+	serialized, err := outs.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return b.Put(txid, serialized)
 }
@@ -0,0 +1,330 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// medianTimeBlocks is the number of ancestors whose median timestamp a
+	// new block has to exceed.
+	medianTimeBlocks = 11
+	// maxFutureBlockTime is how far ahead of the local clock a block may be.
+	maxFutureBlockTime = 2 * time.Hour
+)
+
+// Consensus rules a block can break, wrapped in a ValidationError.
+var (
+	ErrBadProofOfWork       = errors.New("proof of work is invalid")
+	ErrBadMerkleRoot        = errors.New("merkle root does not match the transactions")
+	ErrBadPrevBlock         = errors.New("previous block link is invalid")
+	ErrBadTimestamp         = errors.New("timestamp is out of range")
+	ErrBadCoinbase          = errors.New("coinbase is invalid")
+	ErrBadSubsidy           = errors.New("coinbase claims a wrong subsidy")
+	ErrBadTxID              = errors.New("transaction ID does not match its content")
+	ErrDoubleSpend          = errors.New("output is spent twice")
+	ErrMissingInput         = errors.New("input spends an unknown or spent output")
+	ErrBadValue             = errors.New("transaction outputs exceed its inputs")
+	ErrBadSignature         = errors.New("transaction signature is invalid")
+	ErrBadScriptSig         = errors.New("input unlocks outside its witness")
+	ErrNonFinalTx           = errors.New("transaction is still time locked")
+	ErrBadOutput            = errors.New("transaction output is malformed")
+	ErrBadWitnessCommitment = errors.New("witness commitment does not match the witnesses")
+)
+
+// ValidationError tells which consensus rule a block broke and why. Match the
+// rule with errors.Is.
+type ValidationError struct {
+	Rule   error
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", e.Rule, e.Reason)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Rule
+}
+
+func ruleError(rule error, format string, args ...interface{}) error {
+	return &ValidationError{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidateBlock checks a block against every consensus rule as the next block
+// of the best chain, its transactions are checked against the UTXO set.
+func (bc *Blockchain) ValidateBlock(block *Block) error {
+	return bc.DB.View(func(tx *bolt.Tx) error {
+		tip := tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+		if !bytes.Equal(block.PrevBlockHash, tip) {
+			return ruleError(ErrBadPrevBlock, "block does not extend the tip %x", tip)
+		}
+
+		if err := checkBlock(tx, block); err != nil {
+			return err
+		}
+
+		return checkBlockTransactions(tx, block)
+	})
+}
+
+// checkBlock checks the rules that only depend on the block and its
+// ancestors, so blocks of side branches can be checked before being stored.
+func checkBlock(tx *bolt.Tx, block *Block) error {
+	if len(block.PrevBlockHash) == 0 {
+		return ruleError(ErrBadPrevBlock, "only the genesis block has no parent")
+	}
+
+	parent, err := getStoredBlock(tx, block.PrevBlockHash)
+	if errors.Is(err, ErrBlockNotFound) {
+		return ruleError(ErrBadPrevBlock, "parent %x is not known", block.PrevBlockHash)
+	}
+	if err != nil {
+		return err
+	}
+
+	if block.Height != parent.Height+1 {
+		return ruleError(ErrBadPrevBlock, "height %d does not follow parent height %d", block.Height, parent.Height)
+	}
+
+	requiredBits, err := nextBits(tx, parent)
+	if err != nil {
+		return err
+	}
+
+	valid, err := NewProofOfWork(block).Validate(requiredBits)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ruleError(ErrBadProofOfWork, "hash %x does not meet required bits %08x", block.Hash, requiredBits)
+	}
+
+	medianTime, err := medianTimePast(tx, parent)
+	if err != nil {
+		return err
+	}
+	if block.Timestamp <= medianTime {
+		return ruleError(ErrBadTimestamp, "%d is not after the median time %d of previous blocks", block.Timestamp, medianTime)
+	}
+	if maxTime := time.Now().Add(maxFutureBlockTime).Unix(); block.Timestamp > maxTime {
+		return ruleError(ErrBadTimestamp, "%d is too far in the future", block.Timestamp)
+	}
+
+	if len(block.Transactions) == 0 || !block.Transactions[0].IsCoinbase() {
+		return ruleError(ErrBadCoinbase, "first transaction is not a coinbase")
+	}
+
+	merkleRoot, err := block.HashTransaction()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(merkleRoot, block.MerkleRoot) {
+		return ruleError(ErrBadMerkleRoot, "header commits to %x, transactions hash to %x", block.MerkleRoot, merkleRoot)
+	}
+
+	if err = checkWitnessCommitment(block); err != nil {
+		return err
+	}
+
+	spent := make(map[string]bool)
+	for i, btx := range block.Transactions {
+		if i > 0 && btx.IsCoinbase() {
+			return ruleError(ErrBadCoinbase, "transaction %d is a second coinbase", i)
+		}
+
+		id, err := btx.ComputeID()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(id, btx.ID) {
+			return ruleError(ErrBadTxID, "transaction %x hashes to %x", btx.ID, id)
+		}
+
+		if !btx.IsFinal(block.Height, medianTime) {
+			return ruleError(ErrNonFinalTx, "transaction %x is locked until %d", btx.ID, btx.LockTime)
+		}
+
+		if err = checkTransaction(btx); err != nil {
+			return err
+		}
+
+		if btx.IsCoinbase() {
+			continue
+		}
+
+		for _, vin := range btx.Vin {
+			op := transaction.OutpointKey(vin.Txid, vin.Vout)
+			if spent[op] {
+				return ruleError(ErrDoubleSpend, "%s is spent twice in the block", op)
+			}
+			spent[op] = true
+		}
+	}
+
+	return nil
+}
+
+// checkTransaction checks the rules a transaction follows on its own, before
+// looking at the outputs it spends.
+func checkTransaction(btx *transaction.Transaction) error {
+	if len(btx.Vin) == 0 {
+		return ruleError(ErrMissingInput, "transaction %x has no inputs", btx.ID)
+	}
+
+	if btx.IsCoinbase() && btx.HasWitness() {
+		return ruleError(ErrBadCoinbase, "coinbase %x carries a witness", btx.ID)
+	}
+
+	if btx.HasUncommittedScriptSig() {
+		return ruleError(ErrBadScriptSig, "transaction %x has an unlocking script its ID does not commit to", btx.ID)
+	}
+
+	outputs := 0
+	for i, out := range btx.Vout {
+		if out.Value < 0 {
+			return ruleError(ErrBadOutput, "output %d of transaction %x has a negative value", i, btx.ID)
+		}
+
+		var ok bool
+		if outputs, ok = addValue(outputs, out.Value); !ok {
+			return ruleError(ErrBadOutput, "outputs of transaction %x exceed the supply of %d", btx.ID, transaction.MaxSupply)
+		}
+
+		if !out.IsUnspendable() {
+			continue
+		}
+
+		if data, ok := script.ExtractNullData(out.ScriptPubKey); !ok || len(data) > script.MaxDataCarrierSize {
+			return ruleError(ErrBadOutput, "output %d of transaction %x is not a data output of at most %d bytes", i, btx.ID, script.MaxDataCarrierSize)
+		}
+	}
+
+	return nil
+}
+
+// checkBlockTransactions checks the block transactions against the UTXO set,
+// which has to be at the block's parent.
+func checkBlockTransactions(tx *bolt.Tx, block *Block) error {
+	fees := 0
+	// Outputs created earlier in the block can be spent by later transactions.
+	created := make(map[string]transaction.TXOutput)
+
+	for _, btx := range block.Transactions[1:] {
+		inputs := 0
+		prevOuts := make(map[string]transaction.TXOutput)
+
+		for _, vin := range btx.Vin {
+			op := transaction.OutpointKey(vin.Txid, vin.Vout)
+
+			out, ok := created[op]
+			if !ok {
+				found, err := findUnspentOutput(tx, vin.Txid, vin.Vout)
+				if errors.Is(err, ErrOutputNotFound) {
+					return ruleError(ErrMissingInput, "transaction %x spends %s", btx.ID, op)
+				}
+				if err != nil {
+					return err
+				}
+
+				out = *found
+			}
+
+			delete(created, op)
+			prevOuts[op] = out
+
+			if inputs, ok = addValue(inputs, out.Value); !ok {
+				return ruleError(ErrBadValue, "inputs of transaction %x exceed the supply of %d", btx.ID, transaction.MaxSupply)
+			}
+		}
+
+		outputs := 0
+		for _, out := range btx.Vout {
+			var ok bool
+			if outputs, ok = addValue(outputs, out.Value); !ok {
+				return ruleError(ErrBadValue, "outputs of transaction %x exceed the supply of %d", btx.ID, transaction.MaxSupply)
+			}
+		}
+
+		if outputs > inputs {
+			return ruleError(ErrBadValue, "transaction %x spends %d but creates %d", btx.ID, inputs, outputs)
+		}
+
+		var ok bool
+		if fees, ok = addValue(fees, inputs-outputs); !ok {
+			return ruleError(ErrBadValue, "fees of the block exceed the supply of %d", transaction.MaxSupply)
+		}
+
+		verified, err := btx.Verify(prevOuts)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return ruleError(ErrBadSignature, "transaction %x", btx.ID)
+		}
+
+		for outIdx, out := range btx.Vout {
+			if !out.IsUnspendable() {
+				created[transaction.OutpointKey(btx.ID, outIdx)] = out
+			}
+		}
+	}
+
+	coinbaseValue := 0
+	for _, out := range block.Transactions[0].Vout {
+		var ok bool
+		if coinbaseValue, ok = addValue(coinbaseValue, out.Value); !ok {
+			return ruleError(ErrBadSubsidy, "coinbase outputs exceed the supply of %d", transaction.MaxSupply)
+		}
+	}
+
+	// The subsidy follows the halving schedule and stops at the supply cap,
+	// a miner may claim less than it and the fees but never more.
+	if limit := transaction.Subsidy(block.Height) + fees; coinbaseValue > limit {
+		return ruleError(ErrBadSubsidy, "coinbase claims %d, more than subsidy and fees of %d", coinbaseValue, limit)
+	}
+
+	return nil
+}
+
+// addValue adds value to a running total of coins. It fails when either is
+// negative or the sum goes past the supply cap, which no valid total can
+// exceed, so sums never overflow.
+func addValue(total, value int) (int, bool) {
+	if total < 0 || value < 0 || value > transaction.MaxSupply || total > transaction.MaxSupply-value {
+		return total, false
+	}
+
+	return total + value, true
+}
+
+// medianTimePast returns the median timestamp of the last medianTimeBlocks
+// blocks ending at the given one.
+func medianTimePast(tx *bolt.Tx, block *Block) (int64, error) {
+	var timestamps []int64
+
+	for len(timestamps) < medianTimeBlocks {
+		timestamps = append(timestamps, block.Timestamp)
+
+		if len(block.PrevBlockHash) == 0 {
+			break
+		}
+
+		var err error
+		block, err = getStoredBlock(tx, block.PrevBlockHash)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	return timestamps[len(timestamps)/2], nil
+}
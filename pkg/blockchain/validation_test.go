@@ -0,0 +1,166 @@
+package blockchain
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/boltdb/bolt"
+)
+
+func testOutputs(t *testing.T, values ...int) []transaction.TXOutput {
+	t.Helper()
+
+	_, address := newTestWallet(t)
+
+	var outs []transaction.TXOutput
+	for _, value := range values {
+		out, err := transaction.NewTXOutput(value, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outs = append(outs, *out)
+	}
+
+	return outs
+}
+
+func TestCheckTransactionValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []int
+		err    error
+	}{
+		{name: "outputs up to the supply", values: []int{transaction.MaxSupply - 1, 1}},
+		{name: "negative output", values: []int{5, -1}, err: ErrBadOutput},
+		{name: "output above the supply", values: []int{transaction.MaxSupply + 1}, err: ErrBadOutput},
+		{name: "outputs adding up past the supply", values: []int{transaction.MaxSupply, 1}, err: ErrBadOutput},
+		{name: "outputs wrapping around", values: []int{math.MaxInt64, math.MaxInt64, 2}, err: ErrBadOutput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &transaction.Transaction{
+				Vin:  []transaction.TXInput{{Txid: []byte{1}, Vout: 0}},
+				Vout: testOutputs(t, tt.values...),
+			}
+
+			if err := checkTransaction(tx); !errors.Is(err, tt.err) {
+				t.Errorf("checkTransaction = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCheckCoinbaseValue(t *testing.T) {
+	subsidy := transaction.Subsidy(1)
+
+	tests := []struct {
+		name   string
+		values []int
+		err    error
+	}{
+		{name: "full subsidy", values: []int{subsidy - 1, 1}},
+		{name: "more than the subsidy", values: []int{subsidy, 1}, err: ErrBadSubsidy},
+		{name: "outputs wrapping around", values: []int{math.MaxInt64, math.MaxInt64, 2}, err: ErrBadSubsidy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc, _ := newTestChain(t)
+
+			coinbase := &transaction.Transaction{
+				Vin:  []transaction.TXInput{{Txid: []byte{}, Vout: -1}},
+				Vout: testOutputs(t, tt.values...),
+			}
+			block := &Block{Transactions: []*transaction.Transaction{coinbase}, Height: 1}
+
+			err := bc.DB.View(func(tx *bolt.Tx) error {
+				return checkBlockTransactions(tx, block)
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("checkBlockTransactions = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMempoolRejectsValueOverflow(t *testing.T) {
+	bc, w := newTestChain(t)
+
+	locking, err := transaction.LockingScript(string(w.GetAddress()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	coins, err := (UTXOSet{Blockchain: bc}).SpendableCoins(locking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coin := coins[0]
+	prevOut, err := (UTXOSet{Blockchain: bc}).FindOutput(coin.Txid, coin.Vout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The outputs sum to 0 in int arithmetic, less than the coin spent.
+	tx := &transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: coin.Txid, Vout: coin.Vout}},
+		Vout: testOutputs(t, math.MaxInt64, math.MaxInt64, 2),
+	}
+	if tx.ID, err = tx.Hash(); err != nil {
+		t.Fatal(err)
+	}
+	prevOuts := map[string]transaction.TXOutput{transaction.OutpointKey(coin.Txid, coin.Vout): *prevOut}
+	if err = tx.Sign(w.PrivateKey, prevOuts, transaction.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = (Mempool{Blockchain: bc}).Add(tx); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("Add = %v, want %v", err, ErrInvalidTransaction)
+	}
+}
+
+func TestMempoolRejectsBadID(t *testing.T) {
+	bc, w := newTestChain(t)
+	_, to := newTestWallet(t)
+
+	tx := newTestPayment(t, bc, w, to, 3, 1)
+	tx.ID = []byte("bogus id")
+
+	if err := (Mempool{Blockchain: bc}).Add(tx); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("Add = %v, want %v", err, ErrInvalidTransaction)
+	}
+}
+
+func TestCheckTransactionScriptSig(t *testing.T) {
+	_, address := newTestWallet(t)
+	coinbase, err := transaction.NewCoinbaseTX(address, "reward", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tx   *transaction.Transaction
+		err  error
+	}{
+		{name: "coinbase data", tx: coinbase},
+		{name: "witness", tx: &transaction.Transaction{
+			Vin:  []transaction.TXInput{{Txid: []byte{1}, Vout: 0, Witness: [][]byte{[]byte("signature")}}},
+			Vout: testOutputs(t, 1),
+		}},
+		{name: "unlocking script", tx: &transaction.Transaction{
+			Vin:  []transaction.TXInput{{Txid: []byte{1}, Vout: 0, ScriptSig: []byte("signature")}},
+			Vout: testOutputs(t, 1),
+		}, err: ErrBadScriptSig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTransaction(tt.tx); !errors.Is(err, tt.err) {
+				t.Errorf("checkTransaction = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
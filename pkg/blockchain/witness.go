@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"bytes"
+
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+)
+
+// witnessBlockVersion is the first block version whose merkle root covers
+// transaction IDs only, witnesses being committed to by the coinbase.
+const witnessBlockVersion = 2
+
+// witnessCommitmentTag starts the data output of the coinbase carrying the
+// witness merkle root.
+var witnessCommitmentTag = []byte{0xaa, 0x21, 0xa9, 0xed}
+
+// hasWitness reports whether any transaction of the block carries a witness.
+func (b *Block) hasWitness() bool {
+	for _, tx := range b.Transactions {
+		if tx.HasWitness() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WitnessRoot computes the merkle root of the block witness IDs, the hashes of
+// the full transaction encodings. The coinbase, which cannot commit to itself,
+// counts as zeros.
+func (b *Block) WitnessRoot() ([]byte, error) {
+	wtxids := [][]byte{make([]byte, hashLength)}
+
+	for _, tx := range b.Transactions[1:] {
+		wtxid, err := tx.Hash()
+		if err != nil {
+			return nil, err
+		}
+
+		wtxids = append(wtxids, wtxid)
+	}
+
+	return NewMerkleTree(wtxids).RootNode.Data, nil
+}
+
+// witnessCommitment returns the witness root the coinbase commits to, nil if
+// it has no commitment. The last one counts when there are several.
+func witnessCommitment(coinbase *transaction.Transaction) []byte {
+	for i := len(coinbase.Vout) - 1; i >= 0; i-- {
+		data, ok := script.ExtractNullData(coinbase.Vout[i].ScriptPubKey)
+		if ok && len(data) == len(witnessCommitmentTag)+hashLength && bytes.HasPrefix(data, witnessCommitmentTag) {
+			return data[len(witnessCommitmentTag):]
+		}
+	}
+
+	return nil
+}
+
+// addWitnessCommitment adds the witness root to the coinbase of a block with
+// witnesses, whose ID changes with it.
+func (b *Block) addWitnessCommitment() error {
+	if !b.hasWitness() {
+		return nil
+	}
+
+	root, err := b.WitnessRoot()
+	if err != nil {
+		return err
+	}
+
+	out, err := transaction.NewDataOutput(append(append([]byte{}, witnessCommitmentTag...), root...))
+	if err != nil {
+		return err
+	}
+
+	coinbase := b.Transactions[0]
+	coinbase.Vout = append(coinbase.Vout, *out)
+	coinbase.ID, err = coinbase.ComputeID()
+
+	return err
+}
+
+// checkWitnessCommitment checks that the witnesses of a block are committed
+// to by its coinbase. Blocks from before witnesses cannot carry any.
+func checkWitnessCommitment(block *Block) error {
+	if block.Version < witnessBlockVersion {
+		if block.hasWitness() {
+			return ruleError(ErrBadWitnessCommitment, "version %d block carries witnesses", block.Version)
+		}
+		return nil
+	}
+
+	commitment := witnessCommitment(block.Transactions[0])
+	if commitment == nil {
+		if block.hasWitness() {
+			return ruleError(ErrBadWitnessCommitment, "coinbase does not commit to the block witnesses")
+		}
+		return nil
+	}
+
+	root, err := block.WitnessRoot()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, commitment) {
+		return ruleError(ErrBadWitnessCommitment, "coinbase commits to %x, witnesses hash to %x", commitment, root)
+	}
+
+	return nil
+}
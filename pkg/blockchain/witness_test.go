@@ -0,0 +1,150 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/blockmandu/pkg/transaction"
+)
+
+// newWitnessTestBlock returns a block of the given version with a coinbase
+// and a transaction whose input carries witness, if any.
+func newWitnessTestBlock(t *testing.T, version int32, witness [][]byte) *Block {
+	t.Helper()
+
+	_, address := newTestWallet(t)
+
+	coinbase, err := transaction.NewCoinbaseTX(address, "", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := &transaction.Transaction{
+		Vin:  []transaction.TXInput{{Txid: []byte{1}, Vout: 0, Witness: witness}},
+		Vout: testOutputs(t, 1),
+	}
+	if tx.ID, err = tx.ComputeID(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Block{BlockHeader: BlockHeader{Version: version}, Transactions: []*transaction.Transaction{coinbase, tx}}
+}
+
+// commitTestWitnesses adds the output committing to the block witnesses to
+// its coinbase, with the root first changed by tamper.
+func commitTestWitnesses(t *testing.T, block *Block, tamper func(root []byte)) {
+	t.Helper()
+
+	root, err := block.WitnessRoot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tamper(root)
+
+	out, err := transaction.NewDataOutput(append(append([]byte{}, witnessCommitmentTag...), root...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	coinbase := block.Transactions[0]
+	coinbase.Vout = append(coinbase.Vout, *out)
+}
+
+func TestCheckWitnessCommitment(t *testing.T) {
+	witness := [][]byte{[]byte("sig"), []byte("key")}
+	keep := func(root []byte) {}
+	flip := func(root []byte) { root[0] ^= 0xff }
+
+	tests := []struct {
+		name  string
+		block func(t *testing.T) *Block
+		err   error
+	}{
+		{
+			name:  "no witnesses",
+			block: func(t *testing.T) *Block { return newWitnessTestBlock(t, witnessBlockVersion, nil) },
+		},
+		{
+			name: "witnesses committed",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				commitTestWitnesses(t, block, keep)
+				return block
+			},
+		},
+		{
+			name: "witnesses committed while the block is built",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				if err := block.addWitnessCommitment(); err != nil {
+					t.Fatal(err)
+				}
+				return block
+			},
+		},
+		{
+			name: "witness replaced after the commitment",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				commitTestWitnesses(t, block, keep)
+				block.Transactions[1].Vin[0].Witness = [][]byte{[]byte("other sig"), []byte("key")}
+				return block
+			},
+			err: ErrBadWitnessCommitment,
+		},
+		{
+			name:  "witnesses without commitment",
+			block: func(t *testing.T) *Block { return newWitnessTestBlock(t, witnessBlockVersion, witness) },
+			err:   ErrBadWitnessCommitment,
+		},
+		{
+			name: "wrong commitment",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				commitTestWitnesses(t, block, flip)
+				return block
+			},
+			err: ErrBadWitnessCommitment,
+		},
+		{
+			name: "last commitment counts",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				commitTestWitnesses(t, block, flip)
+				commitTestWitnesses(t, block, keep)
+				return block
+			},
+		},
+		{
+			name: "wrong last commitment",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion, witness)
+				commitTestWitnesses(t, block, keep)
+				commitTestWitnesses(t, block, flip)
+				return block
+			},
+			err: ErrBadWitnessCommitment,
+		},
+		{
+			name:  "block from before witnesses",
+			block: func(t *testing.T) *Block { return newWitnessTestBlock(t, witnessBlockVersion-1, nil) },
+		},
+		{
+			name: "witnesses in a block from before witnesses",
+			block: func(t *testing.T) *Block {
+				block := newWitnessTestBlock(t, witnessBlockVersion-1, witness)
+				commitTestWitnesses(t, block, keep)
+				return block
+			},
+			err: ErrBadWitnessCommitment,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkWitnessCommitment(tt.block(t)); !errors.Is(err, tt.err) {
+				t.Errorf("checkWitnessCommitment = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func bumpFeeCmd() *cobra.Command {
+	var node string
+	var fee int
+	cmd := &cobra.Command{
+		Use:   "bumpfee <txid>",
+		Short: "Replace a transaction waiting in the mempool with one paying a higher fee out of its change",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			txid, err := hex.DecodeString(args[0])
+			if err != nil || fee < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			bumpFee(txid, fee, node)
+		},
+	}
+
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "The new fee, higher than the current one (defaults to one more)")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the replacement to the node at this address (host:port) instead of the local mempool")
+
+	return cmd
+}
+
+func bumpFee(txid []byte, fee int, node string) {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	mempool := blockchain.Mempool{Blockchain: bc}
+
+	if fee == 0 {
+		tx, err := mempool.Get(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		if fee, err = mempool.Fee(tx); err != nil {
+			log.Panic(err)
+		}
+		fee++
+	}
+
+	tx, err := mempool.BumpFee(txid, fee)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	w := inputsOwner(bc, tx)
+	if err = bc.SignTransaction(tx, w.PrivateKey, transaction.SigHashAll); err != nil {
+		log.Panic(err)
+	}
+
+	submitTransaction(bc, tx, node, true, "", fee)
+	fmt.Printf("It replaces %x with a fee of %d\n", txid, fee)
+}
+
+// inputsOwner returns the local wallet the inputs of tx belong to.
+func inputsOwner(bc *blockchain.Blockchain, tx *transaction.Transaction) wallet.Wallet {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	prevOuts, err := bc.PrevOutputs(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+	vin := tx.Vin[0]
+	locking := prevOuts[transaction.OutpointKey(vin.Txid, vin.Vout)].ScriptPubKey
+
+	for address := range wallets.Wallets {
+		script, err := transaction.LockingScript(address)
+		if err != nil || !bytes.Equal(script, locking) {
+			continue
+		}
+
+		w, err := wallets.SigningWallet(address)
+		if err != nil {
+			log.Panic(err)
+		}
+		return w
+	}
+
+	log.Panic("ERROR: The inputs do not belong to a local wallet")
+	return wallet.Wallet{}
+}
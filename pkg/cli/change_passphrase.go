@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func changePassphraseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "changepassphrase",
+		Short: "Encrypt the wallet with a new passphrase",
+		Run: func(cmd *cobra.Command, args []string) {
+			changePassphrase()
+		},
+	}
+
+	return cmd
+}
+
+func changePassphrase() {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if wallets.Encryption == nil {
+		log.Panic(wallet.ErrNotEncrypted)
+	}
+
+	oldPassphrase, err := readPassphrase("Current passphrase: ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	newPassphrase, err := readNewPassphrase()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.ChangePassphrase(oldPassphrase, newPassphrase); err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Passphrase changed")
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"encoding/hex"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/spf13/cobra"
+)
+
+func claimHTLCCmd() *cobra.Command {
+	var txid, secret, to, node string
+	var vout, fee int
+	var toMempool bool
+	cmd := &cobra.Command{
+		Use:   "claimhtlc",
+		Short: "Claim the coins of a contract with its secret",
+		Run: func(cmd *cobra.Command, args []string) {
+			if txid == "" || secret == "" || to == "" || fee < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			redeemHTLC(txid, vout, secret, to, node, fee, toMempool)
+		},
+	}
+
+	cmd.Flags().StringVarP(&txid, "txid", "", "", "Transaction holding the contract")
+	cmd.Flags().IntVarP(&vout, "vout", "", 0, "Index of the contract output")
+	cmd.Flags().StringVarP(&secret, "secret", "", "", "The secret in hex")
+	cmd.Flags().StringVarP(&to, "to", "", "", "Address to send the coins to")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+
+	return cmd
+}
+
+// redeemHTLC claims a contract when given a secret and refunds it otherwise.
+func redeemHTLC(txidHex string, vout int, secretHex, to, node string, fee int, toMempool bool) {
+	if !common.ValidateAddress(to) {
+		log.Panic("Err: Recipient address is not valid")
+	}
+
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var secret []byte
+	if secretHex != "" {
+		if secret, err = hex.DecodeString(secretHex); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+
+	tx, err := blockchain.NewHTLCRedeemTransaction(txid, vout, secret, to, fee, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	submitTransaction(bc, tx, node, toMempool, to, fee)
+}
@@ -1,21 +1,56 @@
 package cli
 
 import (
+	"os"
+
+	"github.com/blockmandu/pkg/wallet"
 	"github.com/spf13/cobra"
 )
 
+// nodeID selects the database of the node a command operates on.
+var nodeID string
+
 func Run() {
 	cmd := &cobra.Command{
 		Use:   "blockmandu",
 		Short: "The blockmandu is a cli tool for entrypoint of the blockchain.",
 	}
 
+	// Commands needing the keys of an encrypted wallet ask for its passphrase,
+	// unless walletpassphrase is running.
+	wallet.PassphrasePrompt = func() (string, error) { return readPassphrase("Wallet passphrase: ") }
+
+	cmd.PersistentFlags().StringVar(&nodeID, "node-id", os.Getenv("NODE_ID"), "The node whose blockchain to use (defaults to $NODE_ID)")
+
 	cmd.AddCommand(
 		createBlockchainCmd(),
 		getBalanceCmd(),
+		listTransactionsCmd(),
 		printChainCmd(),
 		sendCmd(),
+		sendManyCmd(),
 		createWalletCmd(),
+		importAddressCmd(),
+		restoreWalletCmd(),
+		encryptWalletCmd(),
+		walletPassphraseCmd(),
+		walletLockCmd(),
+		changePassphraseCmd(),
+		startNodeCmd(),
+		mineCmd(),
+		supplyCmd(),
+		createMultiSigCmd(),
+		spendMultiSigCmd(),
+		signTxCmd(),
+		submitTxCmd(),
+		createHTLCCmd(),
+		claimHTLCCmd(),
+		refundHTLCCmd(),
+		htlcSecretCmd(),
+		notarizeCmd(),
+		verifyNotaryCmd(),
+		migrateDBCmd(),
+		bumpFeeCmd(),
 	)
 
 	cobra.CheckErr(cmd.Execute())
@@ -29,7 +29,7 @@ func createBlockchainCmd() *cobra.Command {
 }
 
 func createBlockchain(address string) {
-	bc, err := blockchain.CreateBlockchain(address)
+	bc, err := blockchain.CreateBlockchain(address, nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+	"github.com/spf13/cobra"
+)
+
+const htlcSecretLen = 32
+
+func createHTLCCmd() *cobra.Command {
+	var to, from, secretHash, node string
+	var amount, fee int
+	var lockTime int64
+	var toMempool bool
+	cmd := &cobra.Command{
+		Use:   "createhtlc",
+		Short: "Lock coins for an address until a secret is revealed, refundable after a lock time",
+		Run: func(cmd *cobra.Command, args []string) {
+			if amount <= 0 || fee < 0 || lockTime <= 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			createHTLC(from, to, secretHash, node, amount, fee, lockTime, toMempool)
+		},
+	}
+
+	cmd.Flags().StringVarP(&to, "to", "", "", "Address that can claim the coins with the secret")
+	cmd.Flags().StringVarP(&from, "from", "", "", "Source wallet address, which can take a refund after the lock time")
+	cmd.Flags().IntVarP(&amount, "amount", "a", 0, "Amount to lock")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().Int64VarP(&lockTime, "locktime", "", 0, "Block height, or unix time from 500000000 on, after which a refund is possible")
+	cmd.Flags().StringVarP(&secretHash, "secret-hash", "", "", "SHA-256 of the secret in hex, a new secret is generated when empty")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+
+	return cmd
+}
+
+func createHTLC(from, to, secretHashHex, node string, amount, fee int, lockTime int64, toMempool bool) {
+	senderVersion, senderHash, err := common.DecodeAddress(from)
+	if err != nil || senderVersion != common.PubKeyHashVersion {
+		log.Panic("Err: Sender address is not a valid wallet address")
+	}
+
+	recipientVersion, recipientHash, err := common.DecodeAddress(to)
+	if err != nil || recipientVersion != common.PubKeyHashVersion {
+		log.Panic("Err: Recipient address is not a valid wallet address")
+	}
+
+	var secret, secretHash []byte
+	if secretHashHex == "" {
+		secret = make([]byte, htlcSecretLen)
+		if _, err = rand.Read(secret); err != nil {
+			log.Panic(err)
+		}
+		secretHash = script.SecretHash(secret)
+	} else if secretHash, err = hex.DecodeString(secretHashHex); err != nil {
+		log.Panic(err)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+
+	contract := script.HTLC{
+		SecretHash:    secretHash,
+		RecipientHash: recipientHash,
+		SenderHash:    senderHash,
+		LockTime:      lockTime,
+	}
+
+	tx, err := blockchain.NewHTLCTransaction(from, contract, amount, fee, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	submitTransaction(bc, tx, node, toMempool, from, fee)
+
+	fmt.Printf("Contract %x output 0 locks %d until %d\n", tx.ID, amount, lockTime)
+	fmt.Printf("Secret hash: %x\n", secretHash)
+	if secret != nil {
+		fmt.Printf("Secret: %x (keep it private until you claim)\n", secret)
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func createMultiSigCmd() *cobra.Command {
+	var required int
+	var keys []string
+	cmd := &cobra.Command{
+		Use:   "createmultisig",
+		Short: "Create an address whose coins need the signatures of m of the given keys",
+		Run: func(cmd *cobra.Command, args []string) {
+			if required <= 0 || len(keys) == 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			createMultiSig(required, keys)
+		},
+	}
+
+	cmd.Flags().IntVarP(&required, "required", "m", 0, "Number of signatures needed to spend")
+	cmd.Flags().StringSliceVarP(&keys, "keys", "", nil, "Public keys in hex, or addresses of local wallets, in a fixed order")
+
+	return cmd
+}
+
+func createMultiSig(required int, keys []string) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var pubKeys [][]byte
+	for _, key := range keys {
+		if w, ok := wallets.Wallets[key]; ok {
+			if w.PublicKey == nil {
+				log.Panicf("ERROR: The public key of %s is unknown", key)
+			}
+			pubKeys = append(pubKeys, w.PublicKey)
+			continue
+		}
+
+		pubKey, err := hex.DecodeString(key)
+		if err == nil {
+			_, err = common.ParsePubKey(pubKey)
+		}
+		if err != nil {
+			log.Panicf("ERROR: %s is neither a local wallet nor a public key", key)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+
+	address, err := wallets.AddMultiSig(required, pubKeys)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Your new %d-of-%d address: %s\n", required, len(pubKeys), address)
+}
@@ -9,28 +9,66 @@ import (
 )
 
 func createWalletCmd() *cobra.Command {
+	var words int
 	cmd := &cobra.Command{
 		Use:   "createwallet",
-		Short: "Create a blockchain with genesis block",
+		Short: "Derive a new address from the wallet seed, creating the seed first if needed",
 		Run: func(cmd *cobra.Command, args []string) {
-			createWallet()
+			createWallet(words)
 		},
 	}
 
+	cmd.Flags().IntVarP(&words, "words", "", 12, "Number of words, 12 or 24, of the mnemonic phrase of a new seed")
+
 	return cmd
 }
 
-func createWallet() {
+func createWallet(words int) {
 	wallets, err := wallet.NewWallets()
 	if err != nil {
 		log.Panic(err)
 	}
 
+	// The seed of an encrypted wallet is only seen once it is unlocked.
+	if err = wallets.EnsureUnlocked(); err != nil {
+		log.Panic(err)
+	}
+
+	// Keys created before the seed are not derived from it.
+	var mnemonic string
+	unseeded := false
+	if wallets.Seed == nil {
+		if mnemonic, err = wallets.NewSeed(words); err != nil {
+			log.Panic(err)
+		}
+
+		for _, w := range wallets.Wallets {
+			unseeded = unseeded || !w.WatchOnly
+		}
+	}
+
 	address, err := wallets.CreateWallet()
 	if err != nil {
 		log.Panic(err)
 	}
 
-	wallets.SaveToFile()
+	w, err := wallets.GetWallet(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// The phrase is only shown once the seed it backs up is saved.
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	if mnemonic != "" {
+		fmt.Printf("Your new mnemonic phrase, write it down to restore the wallet with restorewallet:\n\n  %s\n\n", mnemonic)
+		if unseeded {
+			fmt.Println("Addresses created before it are not derived from it, keep backing up wallet.dat for them.")
+		}
+	}
+
 	fmt.Printf("Your new address: %s\n", address)
+	fmt.Printf("Public key: %x\n", w.PublicKey)
 }
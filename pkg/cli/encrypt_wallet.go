@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func encryptWalletCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryptwallet",
+		Short: "Encrypt the private keys and seed of the wallet file with a passphrase",
+		Run: func(cmd *cobra.Command, args []string) {
+			encryptWallet()
+		},
+	}
+
+	return cmd
+}
+
+func encryptWallet() {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if wallets.Encryption != nil {
+		log.Panic(wallet.ErrAlreadyEncrypted)
+	}
+
+	passphrase, err := readNewPassphrase()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.Encrypt(passphrase); err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet encrypted, commands signing with it ask for the passphrase. Backups made before hold the keys unencrypted.")
+}
@@ -3,10 +3,12 @@ package cli
 import (
 	"fmt"
 	"log"
-	"os"
+	"sort"
 
 	"github.com/blockmandu/pkg/blockchain"
 	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
 	"github.com/spf13/cobra"
 )
 
@@ -14,14 +16,9 @@ func getBalanceCmd() *cobra.Command {
 	var address string
 	cmd := &cobra.Command{
 		Use:   "getbalance",
-		Short: "Get balance of a given address",
+		Short: "Get balance of a given address, or of every address of the wallet",
 		Run: func(cmd *cobra.Command, args []string) {
-			if address == "" {
-				cmd.Usage()
-				os.Exit(1)
-			}
-
-			if !common.ValidateAddress(address) {
+			if address != "" && !common.ValidateAddress(address) {
 				log.Panic("ERROR: Address is not valid")
 			}
 
@@ -29,30 +26,61 @@ func getBalanceCmd() *cobra.Command {
 		},
 	}
 
-	cmd.Flags().StringVarP(&address, "address", "a", "", "The address to get balance for")
+	cmd.Flags().StringVarP(&address, "address", "a", "", "The address to get balance for, all wallet addresses when empty")
 
 	return cmd
 }
 
 func getBalance(address string) {
-	bc, err := blockchain.NewBlockchain(address)
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	addresses := []string{address}
+	if address == "" {
+		addresses = addresses[:0]
+		for a := range wallets.Wallets {
+			addresses = append(addresses, a)
+		}
+		sort.Strings(addresses)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
 	defer bc.DB.Close()
 
-	balance := 0
-	pubKeyHash := common.Base58Decode([]byte(address))
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
 	UTXOs := bc.FindUTXO()
 
-	for _, out := range UTXOs {
-		for _, x := range out.Outputs {
-			if x.IsLockedWithKey(pubKeyHash) {
-				balance += x.Value
+	total, watched := 0, 0
+	for _, address := range addresses {
+		balance := 0
+		locking, err := transaction.LockingScript(address)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, out := range UTXOs {
+			for _, x := range out.Outputs {
+				if x.IsLockedWithScript(locking) {
+					balance += x.Value
+				}
 			}
 		}
+
+		mark := ""
+		if w, ok := wallets.Wallets[address]; ok && w.WatchOnly {
+			mark = " (watch-only)"
+			watched += balance
+		}
+		total += balance
+
+		fmt.Printf("Balance of '%s': %d%s\n", address, balance, mark)
 	}
 
-	fmt.Printf("Balance of '%s': %d\n", address, balance)
+	if len(addresses) > 1 {
+		fmt.Printf("Total: %d, of which %d watch-only\n", total, watched)
+	}
 }
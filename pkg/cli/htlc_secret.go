@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/spf13/cobra"
+)
+
+func htlcSecretCmd() *cobra.Command {
+	var txid string
+	var vout int
+	cmd := &cobra.Command{
+		Use:   "htlcsecret",
+		Short: "Print the secret revealed by the claim of a contract",
+		Run: func(cmd *cobra.Command, args []string) {
+			if txid == "" {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			htlcSecret(txid, vout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&txid, "txid", "", "", "Transaction holding the contract")
+	cmd.Flags().IntVarP(&vout, "vout", "", 0, "Index of the contract output")
+
+	return cmd
+}
+
+func htlcSecret(txidHex string, vout int) {
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	secret, err := bc.FindHTLCSecret(txid, vout)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Secret: %x\n", secret)
+}
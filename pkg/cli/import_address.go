@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func importAddressCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "importaddress <address or public key>",
+		Short: "Watch an address whose private key is kept elsewhere",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			importAddress(args[0])
+		},
+	}
+
+	return cmd
+}
+
+func importAddress(addressOrPubKey string) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	address, err := wallets.ImportAddress(addressOrPubKey)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Watching %s\n", address)
+}
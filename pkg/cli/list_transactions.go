@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func listTransactionsCmd() *cobra.Command {
+	var address string
+	var count int
+	cmd := &cobra.Command{
+		Use:   "listtransactions",
+		Short: "List the transactions paying to or spending from an address, newest first",
+		Run: func(cmd *cobra.Command, args []string) {
+			if address == "" || count < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			if !common.ValidateAddress(address) {
+				log.Panic("ERROR: Address is not valid")
+			}
+
+			listTransactions(address, count)
+		},
+	}
+
+	cmd.Flags().StringVarP(&address, "address", "a", "", "The address to list the transactions of")
+	cmd.Flags().IntVarP(&count, "count", "n", 0, "List only the latest transactions, all of them when 0")
+
+	return cmd
+}
+
+func listTransactions(address string, count int) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	entries, err := bc.History(address)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	mark := ""
+	if w, ok := wallets.Wallets[address]; ok && w.WatchOnly {
+		mark = " (watch-only)"
+	}
+	fmt.Printf("Transactions of '%s'%s: %d\n", address, mark, len(entries))
+
+	for i := len(entries) - 1; i >= 0 && (count == 0 || len(entries)-i <= count); i-- {
+		entry := entries[i]
+
+		fmt.Printf("\n%x\n", entry.Txid)
+		fmt.Printf("  Amount: %+d\n", entry.Delta)
+		fmt.Printf("  Block: %d, %s, %d confirmation(s)\n", entry.Height, time.Unix(entry.Timestamp, 0).Format(time.DateTime), entry.Confirmations)
+
+		switch {
+		case entry.Coinbase:
+			fmt.Println("  From: coinbase")
+		case entry.Sent > 0:
+			fmt.Printf("  To: %s\n", scriptAddresses(entry.Counterparties))
+		default:
+			fmt.Printf("  From: %s\n", scriptAddresses(entry.Counterparties))
+		}
+	}
+}
+
+// scriptAddresses lists the addresses locking scripts pay to, naming the
+// scripts without one by their kind.
+func scriptAddresses(scripts [][]byte) string {
+	if len(scripts) == 0 {
+		return "-"
+	}
+
+	var addresses []string
+	for _, locking := range scripts {
+		address, ok := transaction.ScriptAddress(locking)
+		if !ok {
+			address = "non-standard script"
+		}
+		addresses = append(addresses, address)
+	}
+
+	return strings.Join(addresses, ", ")
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/spf13/cobra"
+)
+
+func migrateDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migratedb",
+		Short: "Rewrite a blockchain database stored with gob in the canonical encoding",
+		Run: func(cmd *cobra.Command, args []string) {
+			migrateDB()
+		},
+	}
+
+	return cmd
+}
+
+func migrateDB() {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	migrated, err := bc.Migrate()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Migrated %d record(s) to the canonical encoding\n", migrated)
+}
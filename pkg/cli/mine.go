@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/spf13/cobra"
+)
+
+func mineCmd() *cobra.Command {
+	var address string
+	var maxTxs int
+	cmd := &cobra.Command{
+		Use:   "mine",
+		Short: "Mine the transactions waiting in the mempool into a new block",
+		Run: func(cmd *cobra.Command, args []string) {
+			if address == "" {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			if !common.ValidateAddress(address) {
+				log.Panic("ERROR: Address is not valid")
+			}
+
+			mine(address, maxTxs)
+		},
+	}
+
+	cmd.Flags().StringVarP(&address, "address", "a", "", "The address to send the block reward to")
+	cmd.Flags().IntVarP(&maxTxs, "max", "", 0, "Maximum number of transactions to include (0 for all)")
+
+	return cmd
+}
+
+func mine(address string, maxTxs int) {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	mempool := blockchain.Mempool{Blockchain: bc}
+	block, err := mempool.Mine(address, maxTxs)
+	if errors.Is(err, blockchain.ErrEmptyMempool) {
+		fmt.Println("Nothing to mine, the mempool is empty.")
+		return
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Mined block %x with %d transaction(s)\n", block.Hash, len(block.Transactions)-1)
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/spf13/cobra"
+)
+
+func notarizeCmd() *cobra.Command {
+	var from, node string
+	var fee int
+	var toMempool bool
+	cmd := &cobra.Command{
+		Use:   "notarize <file>",
+		Short: "Anchor the SHA-256 of a file in the chain",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if from == "" || fee < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			notarize(args[0], from, node, fee, toMempool)
+		},
+	}
+
+	cmd.Flags().StringVarP(&from, "from", "", "", "Wallet address paying for the transaction")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+
+	return cmd
+}
+
+func fileHash(path string) []byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	hash := sha256.Sum256(content)
+	return hash[:]
+}
+
+func notarize(path, from, node string, fee int, toMempool bool) {
+	if !common.ValidateAddress(from) {
+		log.Panic("Err: Sender address is not valid")
+	}
+
+	hash := fileHash(path)
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+
+	tx, err := blockchain.NewDataTransaction(from, hash, fee, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	submitTransaction(bc, tx, node, toMempool, from, fee)
+
+	fmt.Printf("SHA-256 %x of %s anchored by transaction %x\n", hash, path, tx.ID)
+}
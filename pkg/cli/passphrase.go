@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// stdin is shared by the prompts, so that passphrases piped in one per line
+// are read in turn.
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase prompts for a passphrase, without echoing it when reading
+// from a terminal.
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(passphrase), err
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readNewPassphrase prompts for a new passphrase twice.
+func readNewPassphrase() (string, error) {
+	passphrase, err := readPassphrase("New passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase == "" {
+		return "", errors.New("passphrase is empty")
+	}
+
+	repeated, err := readPassphrase("Repeat the new passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != repeated {
+		return "", errors.New("passphrases do not match")
+	}
+
+	return passphrase, nil
+}
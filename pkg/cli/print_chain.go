@@ -19,7 +19,7 @@ func printChainCmd() *cobra.Command {
 }
 
 func printChain() {
-	bc, err := blockchain.NewBlockchain("")
+	bc, err := blockchain.NewBlockchain(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -30,11 +30,20 @@ func printChain() {
 	for {
 		block := bci.Next()
 
+		fmt.Printf("Height: %d\n", block.Height)
 		fmt.Printf("Prev. hash: %x\n", block.PrevBlockHash)
 		fmt.Printf("Hash: %x\n", block.Hash)
+		fmt.Printf("Merkle root: %x\n", block.MerkleRoot)
+		fmt.Printf("Timestamp: %d\n", block.Timestamp)
+		fmt.Printf("Bits: %08x\n", block.Bits)
 		pow := blockchain.NewProofOfWork(block)
 
-		valid, _ := pow.Validate()
+		requiredBits, err := bc.RequiredBits(block)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		valid, _ := pow.Validate(requiredBits)
 		fmt.Printf("PoW: %t\n\n", valid)
 
 		if len(block.PrevBlockHash) == 0 {
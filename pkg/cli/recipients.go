@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// recipient is one payment of a sendmany transaction.
+type recipient struct {
+	Address string `json:"address"`
+	Amount  int    `json:"amount"`
+}
+
+// parseRecipient reads a recipient given on the command line as
+// address:amount.
+func parseRecipient(s string) (recipient, error) {
+	address, amount, ok := strings.Cut(s, ":")
+	if !ok {
+		return recipient{}, fmt.Errorf("recipient %q is not address:amount", s)
+	}
+
+	value, err := strconv.Atoi(amount)
+	if err != nil {
+		return recipient{}, fmt.Errorf("recipient %q: %w", s, err)
+	}
+
+	return recipient{Address: address, Amount: value}, nil
+}
+
+// readRecipientsFile reads the recipients of a sendmany transaction, from a
+// JSON array of {"address": .., "amount": ..} objects when the file name ends
+// in .json, otherwise from CSV rows of address,amount with an optional
+// address,amount header.
+func readRecipientsFile(path string) ([]recipient, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var recipients []recipient
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err = json.NewDecoder(f).Decode(&recipients); err != nil {
+			return nil, err
+		}
+
+		return recipients, nil
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first && strings.EqualFold(record[0], "address") {
+			continue
+		}
+
+		amount, err := strconv.Atoi(strings.TrimSpace(record[1]))
+		if err != nil {
+			line, _ := r.FieldPos(1)
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		recipients = append(recipients, recipient{Address: strings.TrimSpace(record[0]), Amount: amount})
+	}
+
+	return recipients, nil
+}
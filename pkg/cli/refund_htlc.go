@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func refundHTLCCmd() *cobra.Command {
+	var txid, to, node string
+	var vout, fee int
+	var toMempool bool
+	cmd := &cobra.Command{
+		Use:   "refundhtlc",
+		Short: "Take back the coins of an unclaimed contract after its lock time",
+		Run: func(cmd *cobra.Command, args []string) {
+			if txid == "" || to == "" || fee < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			redeemHTLC(txid, vout, "", to, node, fee, toMempool)
+		},
+	}
+
+	cmd.Flags().StringVarP(&txid, "txid", "", "", "Transaction holding the contract")
+	cmd.Flags().IntVarP(&vout, "vout", "", 0, "Index of the contract output")
+	cmd.Flags().StringVarP(&to, "to", "", "", "Address to send the coins to")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+
+	return cmd
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func restoreWalletCmd() *cobra.Command {
+	var count int
+	cmd := &cobra.Command{
+		Use:   "restorewallet",
+		Short: "Restore the addresses derived from a mnemonic phrase, read from a prompt or stdin",
+		Run: func(cmd *cobra.Command, args []string) {
+			if count <= 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			restoreWallet(count)
+		},
+	}
+
+	cmd.Flags().IntVarP(&count, "count", "n", 20, "Number of addresses to derive")
+
+	return cmd
+}
+
+func restoreWallet(count int) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// The phrase is not taken as a flag, which would leave it in the shell
+	// history and the process list.
+	mnemonic, err := readPassphrase("Mnemonic phrase: ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	addresses, err := wallets.Restore(mnemonic, count)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.SaveToFile(); err != nil {
+		log.Panic(err)
+	}
+
+	for _, address := range addresses {
+		fmt.Println(address)
+	}
+	fmt.Printf("Restored %d address(es), createwallet derives the next ones\n", len(addresses))
+}
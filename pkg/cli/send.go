@@ -7,34 +7,48 @@ import (
 
 	"github.com/blockmandu/pkg/blockchain"
 	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/network"
 	"github.com/blockmandu/pkg/transaction"
 	"github.com/spf13/cobra"
 )
 
 func sendCmd() *cobra.Command {
-	var to, from string
-	var amount int
+	var to, from, node, coinSelection string
+	var amount, fee int
+	var toMempool, replaceable bool
 	cmd := &cobra.Command{
 		Use:   "send",
 		Short: "Send blockmandu to given address",
 		Run: func(cmd *cobra.Command, args []string) {
-			if amount <= 0 {
+			if amount <= 0 || fee < 0 {
 				cmd.Usage()
 				os.Exit(1)
 			}
 
-			send(from, to, amount)
+			selector, err := blockchain.ParseCoinSelector(coinSelection)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			send(from, to, node, amount, fee, toMempool, replaceable, selector)
 		},
 	}
 
 	cmd.Flags().StringVarP(&to, "to", "", "", "Destination wallet address")
 	cmd.Flags().StringVarP(&from, "from", "", "", "Source wallet address")
 	cmd.Flags().IntVarP(&amount, "amount", "a", 0, "Amount to be sent")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+	cmd.Flags().BoolVarP(&replaceable, "rbf", "", false, "Let the transaction be replaced by one paying a higher fee, see bumpfee")
+	cmd.Flags().StringVarP(&coinSelection, "coin-selection", "", "largest-first", coinSelectionUsage)
 
 	return cmd
 }
 
-func send(from, to string, amount int) {
+const coinSelectionUsage = "How to pick the coins spent: largest-first, smallest-first, branch-and-bound (no change) or random"
+
+func send(from, to, node string, amount, fee int, toMempool, replaceable bool, selector blockchain.CoinSelector) {
 	if !common.ValidateAddress(from) {
 		log.Panic("Err: Sender address is not valid")
 	}
@@ -47,7 +61,7 @@ func send(from, to string, amount int) {
 		log.Panic("Err: Sender and Recipient address cannot be the same")
 	}
 
-	bc, err := blockchain.NewBlockchain(from)
+	bc, err := blockchain.NewBlockchain(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
@@ -55,22 +69,58 @@ func send(from, to string, amount int) {
 
 	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
 
-	tx, err := blockchain.NewUTXOTransaction(from, to, amount, &UTXOSet)
+	output, err := transaction.NewTXOutput(amount, to)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	cbtx, err := transaction.NewCoinbaseTX(from, "")
+	tx, selection, err := blockchain.NewUTXOTransaction(from, []transaction.TXOutput{*output}, fee, replaceable, selector, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	printSelection(selection)
+	submitTransaction(bc, tx, node, toMempool, from, selection.Fee)
+}
+
+func printSelection(selection *blockchain.Selection) {
+	fmt.Printf("Spending %d coin(s) worth %d, %d change, %d fee\n", len(selection.Coins), selection.Value, selection.Change, selection.Fee)
+}
+
+// submitTransaction sends tx to a node, adds it to the local mempool, or
+// mines it right away in a block rewarding miner with the subsidy and fee.
+func submitTransaction(bc *blockchain.Blockchain, tx *transaction.Transaction, node string, toMempool bool, miner string, fee int) {
+	if node != "" {
+		if err := network.SendTx(node, tx); err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("Transaction %x sent to %s\n", tx.ID, node)
+		return
+	}
+
+	if toMempool {
+		mempool := blockchain.Mempool{Blockchain: bc}
+		if err := mempool.Add(tx); err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("Transaction %x added to the mempool\n", tx.ID)
+		return
+	}
+
+	height, err := bc.GetBestHeight()
 	if err != nil {
 		log.Panic(err)
 	}
 
-	block, err := bc.MineBlock([]*transaction.Transaction{cbtx, tx})
+	cbtx, err := transaction.NewCoinbaseTX(miner, "", height+1, fee)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	if err = UTXOSet.Update(block); err != nil {
+	_, err = bc.MineBlock([]*transaction.Transaction{cbtx, tx})
+	if err != nil {
 		log.Panic(err)
 	}
 
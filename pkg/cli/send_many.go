@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/spf13/cobra"
+)
+
+func sendManyCmd() *cobra.Command {
+	var from, file, node, coinSelection string
+	var to []string
+	var fee int
+	var toMempool, replaceable bool
+	cmd := &cobra.Command{
+		Use:   "sendmany",
+		Short: "Pay several addresses in one transaction",
+		Run: func(cmd *cobra.Command, args []string) {
+			if (len(to) == 0 && file == "") || fee < 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			var recipients []recipient
+			for _, s := range to {
+				r, err := parseRecipient(s)
+				if err != nil {
+					log.Panic(err)
+				}
+				recipients = append(recipients, r)
+			}
+
+			if file != "" {
+				fromFile, err := readRecipientsFile(file)
+				if err != nil {
+					log.Panic(err)
+				}
+				recipients = append(recipients, fromFile...)
+			}
+
+			selector, err := blockchain.ParseCoinSelector(coinSelection)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			sendMany(from, node, recipients, fee, toMempool, replaceable, selector)
+		},
+	}
+
+	cmd.Flags().StringVarP(&from, "from", "", "", "Source wallet address")
+	cmd.Flags().StringArrayVarP(&to, "to", "", nil, "A recipient as address:amount, can be repeated")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "CSV (address,amount) or JSON file listing the recipients")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of mining it")
+	cmd.Flags().BoolVarP(&toMempool, "mempool", "", false, "Only add the transaction to the mempool, leaving it to the mine command")
+	cmd.Flags().BoolVarP(&replaceable, "rbf", "", false, "Let the transaction be replaced by one paying a higher fee, see bumpfee")
+	cmd.Flags().StringVarP(&coinSelection, "coin-selection", "", "largest-first", coinSelectionUsage)
+
+	return cmd
+}
+
+func sendMany(from, node string, recipients []recipient, fee int, toMempool, replaceable bool, selector blockchain.CoinSelector) {
+	if !common.ValidateAddress(from) {
+		log.Panic("Err: Sender address is not valid")
+	}
+
+	var outputs []transaction.TXOutput
+	paid := make(map[string]bool)
+	for _, r := range recipients {
+		if !common.ValidateAddress(r.Address) {
+			log.Panicf("Err: Recipient address %s is not valid", r.Address)
+		}
+
+		if r.Address == from {
+			log.Panic("Err: Sender and Recipient address cannot be the same")
+		}
+
+		if paid[r.Address] {
+			log.Panicf("Err: Recipient %s is listed twice", r.Address)
+		}
+		paid[r.Address] = true
+
+		if r.Amount <= 0 {
+			log.Panicf("Err: Amount for %s must be positive", r.Address)
+		}
+
+		output, err := transaction.NewTXOutput(r.Amount, r.Address)
+		if err != nil {
+			log.Panic(err)
+		}
+		outputs = append(outputs, *output)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+
+	tx, selection, err := blockchain.NewUTXOTransaction(from, outputs, fee, replaceable, selector, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	printSelection(selection)
+	submitTransaction(bc, tx, node, toMempool, from, selection.Fee)
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func signTxCmd() *cobra.Command {
+	var file, signer, sigHash string
+	cmd := &cobra.Command{
+		Use:     "signtx",
+		Aliases: []string{"signrawtx"},
+		Short:   "Add the signature of a local wallet to a transaction file, without the chain when the file carries the spent outputs",
+		Run: func(cmd *cobra.Command, args []string) {
+			if file == "" || signer == "" {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			hashType, err := transaction.ParseSigHashType(sigHash)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			signTx(file, signer, hashType)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "The transaction file, updated in place")
+	cmd.Flags().StringVarP(&signer, "signer", "", "", "Address of the wallet to sign with")
+	cmd.Flags().StringVarP(&sigHash, "sighash", "", "ALL", "What the signatures cover: ALL, NONE or SINGLE, optionally with |ANYONECANPAY")
+
+	return cmd
+}
+
+func signTx(file, signer string, hashType transaction.SigHashType) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	w, err := wallets.SigningWallet(signer)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ptx, err := readTxFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Only files without the spent outputs need the chain, which adds them
+	// for the next signers.
+	if ptx.PrevOuts == nil {
+		ptx = withChainPrevOutputs(ptx.Tx)
+	}
+
+	// Signatures commit to the amounts spent, if the file lies about them
+	// the transaction is rejected rather than paying another fee.
+	fmt.Printf("Transaction %x spends %d input(s) into %d output(s) with a fee of %d\n", ptx.Tx.ID, len(ptx.Tx.Vin), len(ptx.Tx.Vout), ptx.Fee())
+
+	if err = ptx.Sign(w.PrivateKey, hashType); err != nil {
+		log.Panic(err)
+	}
+
+	if err = writeTxFile(file, ptx); err != nil {
+		log.Panic(err)
+	}
+
+	complete, err := ptx.Complete()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if complete {
+		fmt.Println("Signed, it is complete and can be submitted")
+		return
+	}
+
+	fmt.Println("Signed, more signatures are needed")
+}
+
+// withChainPrevOutputs pairs tx with the outputs it spends in the chain.
+func withChainPrevOutputs(tx *transaction.Transaction) *transaction.PartialTransaction {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	prevOuts, err := bc.PrevOutputs(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ptx, err := transaction.NewPartialTransaction(tx, prevOuts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return ptx
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func spendMultiSigCmd() *cobra.Command {
+	var to, from, out string
+	var amount, fee int
+	var replaceable bool
+	cmd := &cobra.Command{
+		Use:     "spendmultisig",
+		Aliases: []string{"createrawtx"},
+		Short:   "Write an unsigned transaction spending from a multisig or any other address, for its signers to sign",
+		Run: func(cmd *cobra.Command, args []string) {
+			if amount <= 0 || fee < 0 || out == "" {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			spendMultiSig(from, to, out, amount, fee, replaceable)
+		},
+	}
+
+	cmd.Flags().StringVarP(&to, "to", "", "", "Destination wallet address")
+	cmd.Flags().StringVarP(&from, "from", "", "", "Source address, its keys are not needed, script hash ones must have been created locally")
+	cmd.Flags().IntVarP(&amount, "amount", "a", 0, "Amount to be sent")
+	cmd.Flags().IntVarP(&fee, "fee", "", 0, "Fee left to the miner of the transaction")
+	cmd.Flags().StringVarP(&out, "out", "o", "", "File to write the transaction to")
+	cmd.Flags().BoolVarP(&replaceable, "rbf", "", false, "Let the transaction be replaced by one paying a higher fee")
+
+	return cmd
+}
+
+func spendMultiSig(from, to, out string, amount, fee int, replaceable bool) {
+	if !common.ValidateAddress(from) {
+		log.Panic("Err: Sender address is not valid")
+	}
+
+	if !common.ValidateAddress(to) {
+		log.Panic("Err: Recipient address is not valid")
+	}
+
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+
+	tx, err := blockchain.NewUnsignedTransaction(from, to, amount, fee, &UTXOSet)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if replaceable {
+		if err = tx.SignalReplaceable(); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	addRedeemScripts(wallets, tx, from)
+
+	// The spent outputs go with the transaction for signers without the chain.
+	prevOuts, err := bc.PrevOutputs(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	ptx, err := transaction.NewPartialTransaction(tx, prevOuts)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = writeTxFile(out, ptx); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transaction %x written to %s, pass it to the signers\n", tx.ID, out)
+}
+
+// addRedeemScripts puts the redeem script of a script hash from address in
+// the inputs of tx, where its signers find it.
+func addRedeemScripts(wallets *wallet.Wallets, tx *transaction.Transaction, from string) {
+	if version, _, _ := common.DecodeAddress(from); version != common.ScriptHashVersion {
+		return
+	}
+
+	redeem, ok := wallets.GetScript(from)
+	if !ok {
+		log.Panic("ERROR: Unknown script, run createmultisig with the keys of the address first")
+	}
+
+	for i := range tx.Vin {
+		tx.Vin[i].Witness = script.UnlockScriptHash(nil, redeem)
+	}
+}
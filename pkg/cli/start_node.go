@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/network"
+	"github.com/spf13/cobra"
+)
+
+func startNodeCmd() *cobra.Command {
+	var host, miner string
+	var port int
+	var peers []string
+	cmd := &cobra.Command{
+		Use:   "startnode",
+		Short: "Start a node that syncs blocks and transactions with its peers",
+		Run: func(cmd *cobra.Command, args []string) {
+			if port <= 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			if miner != "" && !common.ValidateAddress(miner) {
+				log.Panic("ERROR: Miner address is not valid")
+			}
+
+			startNode(fmt.Sprintf("%s:%d", host, port), miner, peers)
+		},
+	}
+
+	cmd.Flags().StringVarP(&host, "host", "", "localhost", "Host the node listens on")
+	cmd.Flags().IntVarP(&port, "port", "p", 0, "Port the node listens on")
+	cmd.Flags().StringVarP(&miner, "miner", "m", "", "Enable mining and send rewards to this address")
+	cmd.Flags().StringSliceVarP(&peers, "peers", "", nil, "Addresses (host:port) of the nodes to connect to")
+
+	return cmd
+}
+
+func startNode(address, miner string, peers []string) {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	fmt.Printf("Starting node %s\n", address)
+	if miner != "" {
+		fmt.Printf("Mining is on. Address to receive rewards: %s\n", miner)
+	}
+
+	server := network.NewServer(address, miner, bc, peers)
+	if err = server.Start(); err != nil {
+		log.Panic(err)
+	}
+}
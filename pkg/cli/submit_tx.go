@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/network"
+	"github.com/spf13/cobra"
+)
+
+func submitTxCmd() *cobra.Command {
+	var file, node string
+	cmd := &cobra.Command{
+		Use:     "submittx",
+		Aliases: []string{"submitrawtx"},
+		Short:   "Submit a fully signed transaction file to a node, or to the local mempool",
+		Run: func(cmd *cobra.Command, args []string) {
+			if file == "" {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			submitTx(file, node)
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "The signed transaction file")
+	cmd.Flags().StringVarP(&node, "node", "", "", "Submit the transaction to the node at this address (host:port) instead of the local mempool")
+
+	return cmd
+}
+
+func submitTx(file, node string) {
+	ptx, err := readTxFile(file)
+	if err != nil {
+		log.Panic(err)
+	}
+	tx := ptx.Tx
+
+	// The signatures are checked against the chain, not the outputs the
+	// file says the inputs spend.
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	complete, err := bc.VerifyTransaction(tx)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !complete {
+		log.Panic("ERROR: Transaction is not fully signed")
+	}
+
+	if node != "" {
+		if err = network.SendTx(node, tx); err != nil {
+			log.Panic(err)
+		}
+
+		fmt.Printf("Transaction %x sent to %s\n", tx.ID, node)
+		return
+	}
+
+	mempool := blockchain.Mempool{Blockchain: bc}
+	if err = mempool.Add(tx); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Transaction %x added to the mempool\n", tx.ID)
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/spf13/cobra"
+)
+
+func supplyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "supply",
+		Short: "Display the current block reward and the coins in circulation",
+		Run: func(cmd *cobra.Command, args []string) {
+			supply()
+		},
+	}
+}
+
+func supply() {
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	height, err := bc.GetBestHeight()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	UTXOSet := blockchain.UTXOSet{Blockchain: bc}
+	unspent, err := UTXOSet.TotalValue()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Height: %d\n", height)
+	fmt.Printf("Block reward: %d\n", transaction.Subsidy(height+1))
+	fmt.Printf("Next halving at height: %d\n", transaction.NextHalving(height))
+	fmt.Printf("Issued: %d of %d\n", transaction.IssuedSupply(height), transaction.MaxSupply)
+	fmt.Printf("In circulation: %d\n", unspent)
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/blockmandu/pkg/transaction"
+)
+
+// Transactions passed between signers are stored hex encoded in a file,
+// followed on a second line by the outputs their inputs spend, which lets
+// keys sign them on a machine without the chain. Files holding only the
+// transaction are read without the outputs.
+
+func readTxFile(path string) (*transaction.PartialTransaction, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Fields(string(content))
+	if len(lines) == 0 || len(lines) > 2 {
+		return nil, errors.New("transaction file is not one or two hex lines")
+	}
+
+	data, err := hex.DecodeString(lines[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := transaction.DeserializeTransaction(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 1 {
+		return &transaction.PartialTransaction{Tx: &tx}, nil
+	}
+
+	prevOuts, err := hex.DecodeString(lines[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction.DeserializePartialTransaction(&tx, prevOuts)
+}
+
+func writeTxFile(path string, ptx *transaction.PartialTransaction) error {
+	data, err := ptx.Tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	content := hex.EncodeToString(data) + "\n"
+	if ptx.PrevOuts != nil {
+		content += hex.EncodeToString(ptx.SerializePrevOuts()) + "\n"
+	}
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/spf13/cobra"
+)
+
+func verifyNotaryCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verifynotary <file>",
+		Short: "Find when the SHA-256 of a file was anchored in the chain",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			verifyNotary(args[0])
+		},
+	}
+
+	return cmd
+}
+
+func verifyNotary(path string) {
+	hash := fileHash(path)
+
+	bc, err := blockchain.NewBlockchain(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+	defer bc.DB.Close()
+
+	block, tx, err := bc.FindDataOutput(hash)
+	if errors.Is(err, blockchain.ErrDataNotFound) {
+		fmt.Printf("SHA-256 %x of %s is not anchored in the chain\n", hash, path)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("SHA-256 %x of %s anchored by transaction %x\n", hash, path, tx.ID)
+	fmt.Printf("Block %x at height %d, %s\n", block.Hash, block.Height, time.Unix(block.Timestamp, 0).UTC().Format(time.RFC3339))
+}
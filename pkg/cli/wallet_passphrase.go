@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/blockmandu/pkg/wallet"
+	"github.com/spf13/cobra"
+)
+
+func walletPassphraseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "walletpassphrase <seconds>",
+		Short: "Unlock the encrypted wallet for the given number of seconds, while the command runs",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			seconds, err := strconv.Atoi(args[0])
+			if err != nil || seconds <= 0 {
+				cmd.Usage()
+				os.Exit(1)
+			}
+
+			walletPassphrase(time.Duration(seconds) * time.Second)
+		},
+	}
+
+	return cmd
+}
+
+func walletPassphrase(timeout time.Duration) {
+	wallets, err := wallet.NewWallets()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if wallets.Encryption == nil {
+		log.Panic(wallet.ErrNotEncrypted)
+	}
+
+	passphrase, err := readPassphrase("Wallet passphrase: ")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err = wallets.Unlock(passphrase); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Printf("Wallet unlocked until %s, while this command runs\n", time.Now().Add(timeout).Format(time.TimeOnly))
+
+	if err = wallets.ServeKey(timeout); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println("Wallet locked")
+}
+
+func walletLockCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "walletlock",
+		Short: "Lock the encrypted wallet before its unlock time runs out",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := wallet.Lock(); err != nil {
+				log.Panic(err)
+			}
+
+			fmt.Println("Wallet locked")
+		},
+	}
+
+	return cmd
+}
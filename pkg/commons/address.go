@@ -0,0 +1,55 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Address versions tell what the payload of an address is.
+const (
+	// PubKeyHashVersion addresses carry the hash of a wallet's public key.
+	PubKeyHashVersion = byte(0x00)
+	// MultiSigVersion addresses carry a whole multisig locking script. New
+	// multisig addresses are script hash ones, these are still accepted.
+	MultiSigVersion = byte(0x01)
+	// ScriptHashVersion addresses carry the hash of a redeem script.
+	ScriptHashVersion = byte(0x05)
+)
+
+var ErrBadAddress = errors.New("address is not valid")
+
+// EncodeAddress builds the Base58 address version || payload || checksum.
+func EncodeAddress(version byte, payload []byte) []byte {
+	versionedPayload := append([]byte{version}, payload...)
+	checksum := Checksum(versionedPayload)
+
+	return Base58Encode(append(versionedPayload, checksum...))
+}
+
+// DecodeAddress checks an address and splits it into version and payload.
+func DecodeAddress(address string) (byte, []byte, error) {
+	decoded := Base58Decode([]byte(address))
+	if len(decoded) <= 1+addressChecksumLen {
+		return 0, nil, ErrBadAddress
+	}
+
+	versionedPayload := decoded[:len(decoded)-addressChecksumLen]
+	if !bytes.Equal(decoded[len(decoded)-addressChecksumLen:], Checksum(versionedPayload)) {
+		return 0, nil, ErrBadAddress
+	}
+
+	// Hashes of another length would lock coins to a script nothing unlocks.
+	version, payload := versionedPayload[0], versionedPayload[1:]
+	switch version {
+	case PubKeyHashVersion, ScriptHashVersion:
+		if len(payload) != sha256.Size {
+			return 0, nil, ErrBadAddress
+		}
+	case MultiSigVersion:
+	default:
+		return 0, nil, ErrBadAddress
+	}
+
+	return version, payload, nil
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDecodeAddress(t *testing.T) {
+	hash := HashPubKey([]byte("public key"))
+
+	tests := []struct {
+		name    string
+		address []byte
+		err     error
+	}{
+		{name: "pubkey hash", address: EncodeAddress(PubKeyHashVersion, hash)},
+		{name: "script hash", address: EncodeAddress(ScriptHashVersion, hash)},
+		{name: "multisig script", address: EncodeAddress(MultiSigVersion, []byte("locking script"))},
+		{name: "short pubkey hash", address: EncodeAddress(PubKeyHashVersion, hash[:20]), err: ErrBadAddress},
+		{name: "long pubkey hash", address: EncodeAddress(PubKeyHashVersion, append(hash, 0)), err: ErrBadAddress},
+		{name: "short script hash", address: EncodeAddress(ScriptHashVersion, hash[:31]), err: ErrBadAddress},
+		{name: "empty payload", address: EncodeAddress(PubKeyHashVersion, nil), err: ErrBadAddress},
+		{name: "unknown version", address: EncodeAddress(0x02, hash), err: ErrBadAddress},
+		{name: "bad checksum", address: append(EncodeAddress(PubKeyHashVersion, hash)[:10], 'z'), err: ErrBadAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, payload, err := DecodeAddress(string(tt.address))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("DecodeAddress = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				if ValidateAddress(string(tt.address)) {
+					t.Error("ValidateAddress accepts the address")
+				}
+				return
+			}
+
+			again := EncodeAddress(version, payload)
+			if !bytes.Equal(again, tt.address) {
+				t.Errorf("address encodes back to %s", again)
+			}
+		})
+	}
+}
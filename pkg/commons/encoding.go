@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// The canonical encoding writes integers big-endian with a fixed width and
+// byte strings as a uint32 length followed by the bytes. Every top level
+// record starts with a uint32 format version, so its first byte is 0x00,
+// which never starts the gob streams records were stored as before.
+
+// EncodingVersion is the version written at the start of every record.
+const EncodingVersion = 1
+
+var (
+	ErrTruncated      = errors.New("encoded data is truncated")
+	ErrTrailingData   = errors.New("encoded data has trailing bytes")
+	ErrUnknownVersion = errors.New("unknown encoding version")
+	// ErrLegacyEncoding rejects gob records where only the canonical
+	// encoding is accepted: anything but records already stored.
+	ErrLegacyEncoding = errors.New("gob encoding is only read from stored records")
+)
+
+// IsLegacyEncoding reports whether a record was stored with gob.
+func IsLegacyEncoding(data []byte) bool {
+	return len(data) > 0 && data[0] != 0x00
+}
+
+// Encoder writes a record in the canonical encoding.
+type Encoder struct {
+	buf bytes.Buffer
+}
+
+// NewEncoder starts a record with the encoding version.
+func NewEncoder() *Encoder {
+	return NewVersionedEncoder(EncodingVersion)
+}
+
+// NewVersionedEncoder starts a record of a type whose layout has changed
+// since EncodingVersion with the version of that layout.
+func NewVersionedEncoder(version uint32) *Encoder {
+	e := &Encoder{}
+	e.PutUint32(version)
+
+	return e
+}
+
+func (e *Encoder) PutUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.buf.Write(b[:])
+}
+
+func (e *Encoder) PutInt32(v int32) {
+	e.PutUint32(uint32(v))
+}
+
+func (e *Encoder) PutInt64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	e.buf.Write(b[:])
+}
+
+// PutBytes writes a length-prefixed byte string.
+func (e *Encoder) PutBytes(data []byte) {
+	e.PutUint32(uint32(len(data)))
+	e.buf.Write(data)
+}
+
+// PutRaw writes bytes of a length known to the reader.
+func (e *Encoder) PutRaw(data []byte) {
+	e.buf.Write(data)
+}
+
+func (e *Encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+// Decoder reads a record in the canonical encoding. The first error sticks,
+// later reads return zero values and Finish reports it.
+type Decoder struct {
+	data []byte
+	err  error
+}
+
+// NewDecoder reads and checks the encoding version of a record.
+func NewDecoder(data []byte) *Decoder {
+	d, _ := NewVersionedDecoder(data, EncodingVersion)
+	return d
+}
+
+// NewVersionedDecoder reads the version of a record, which must lie between
+// EncodingVersion and maxVersion, and returns it with the decoder.
+func NewVersionedDecoder(data []byte, maxVersion uint32) (*Decoder, uint32) {
+	d := &Decoder{data: data}
+	version := d.Uint32()
+	if (version < EncodingVersion || version > maxVersion) && d.err == nil {
+		d.err = ErrUnknownVersion
+	}
+
+	return d, version
+}
+
+func (d *Decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	if n < 0 || n > len(d.data) {
+		d.err = ErrTruncated
+		return nil
+	}
+
+	b := d.data[:n]
+	d.data = d.data[n:]
+
+	return b
+}
+
+func (d *Decoder) Uint32() uint32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+func (d *Decoder) Int32() int32 {
+	return int32(d.Uint32())
+}
+
+func (d *Decoder) Int64() int64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// Bytes reads a length-prefixed byte string into a new slice.
+func (d *Decoder) Bytes() []byte {
+	n := d.Uint32()
+	if uint64(n) > uint64(len(d.data)) {
+		d.take(-1)
+		return nil
+	}
+
+	return append([]byte{}, d.take(int(n))...)
+}
+
+// Raw reads n bytes into a new slice.
+func (d *Decoder) Raw(n int) []byte {
+	return append([]byte{}, d.take(n)...)
+}
+
+// Count reads the number of items that follow, each at least minSize bytes
+// long, failing early on counts the remaining data cannot hold.
+func (d *Decoder) Count(minSize int) int {
+	n := d.Uint32()
+	if uint64(n)*uint64(minSize) > uint64(len(d.data)) {
+		d.take(-1)
+		return 0
+	}
+
+	return int(n)
+}
+
+// Finish returns the first error met, or ErrTrailingData if bytes are left.
+func (d *Decoder) Finish() error {
+	if d.err != nil {
+		return d.err
+	}
+
+	if len(d.data) != 0 {
+		return ErrTrailingData
+	}
+
+	return nil
+}
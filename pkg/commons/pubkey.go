@@ -0,0 +1,74 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+)
+
+const (
+	// PubKeyLength is the size of a compressed SEC1 P-256 public key: a 0x02
+	// or 0x03 prefix giving the parity of Y, then X in 32 bytes.
+	PubKeyLength = 33
+	// legacyPubKeyLength is the largest size of the X and Y concatenation
+	// wallets used as public key before, still accepted for the coins locked
+	// to it. Leading zero bytes of X and Y were left out, making it shorter.
+	legacyPubKeyLength = 64
+	coordinateLength   = 32
+)
+
+var ErrBadPubKey = errors.New("public key is not a valid P-256 point")
+
+// MarshalPubKey encodes a public key in compressed SEC1 form.
+func MarshalPubKey(pub *ecdsa.PublicKey) []byte {
+	return elliptic.MarshalCompressed(elliptic.P256(), pub.X, pub.Y)
+}
+
+// LegacyPubKey returns the encoding wallets created before compressed keys
+// used, which an older wallet's addresses are derived from. It keeps their
+// unpadded coordinates, as the address hashes them that way.
+func LegacyPubKey(pub *ecdsa.PublicKey) []byte {
+	return append(pub.X.Bytes(), pub.Y.Bytes()...)
+}
+
+// ParsePubKey decodes a compressed SEC1 public key, or a legacy one,
+// checking that it is a point of the curve.
+func ParsePubKey(data []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+
+	var x, y *big.Int
+	if len(data) == PubKeyLength {
+		x, y = elliptic.UnmarshalCompressed(curve, data)
+	} else {
+		x, y = parseLegacyPubKey(curve, data)
+	}
+
+	if x == nil {
+		return nil, ErrBadPubKey
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// parseLegacyPubKey splits a legacy key into its coordinates. A key shorter
+// than 64 bytes lost the leading zeros of X or Y, it is split where both
+// halves make a point of the curve.
+func parseLegacyPubKey(curve elliptic.Curve, data []byte) (x, y *big.Int) {
+	if len(data) > legacyPubKeyLength {
+		return nil, nil
+	}
+
+	for split := len(data) - coordinateLength; split <= coordinateLength; split++ {
+		if split < 1 || split >= len(data) {
+			continue
+		}
+
+		x, y = new(big.Int).SetBytes(data[:split]), new(big.Int).SetBytes(data[split:])
+		if curve.IsOnCurve(x, y) {
+			return x, y
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// testPubKey returns the first key, by private scalar, whose coordinates
+// pass keep.
+func testPubKey(t *testing.T, keep func(x, y *big.Int) bool) *ecdsa.PublicKey {
+	t.Helper()
+
+	curve := elliptic.P256()
+	for d := int64(1); d < 100000; d++ {
+		x, y := curve.ScalarBaseMult(big.NewInt(d).Bytes())
+		if keep(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	t.Fatal("no key found")
+	return nil
+}
+
+func TestParsePubKey(t *testing.T) {
+	short := func(n *big.Int) bool { return len(n.Bytes()) < coordinateLength }
+
+	keys := []struct {
+		name string
+		key  *ecdsa.PublicKey
+	}{
+		{name: "full coordinates", key: testPubKey(t, func(x, y *big.Int) bool { return !short(x) && !short(y) })},
+		{name: "short X", key: testPubKey(t, func(x, y *big.Int) bool { return short(x) })},
+		{name: "short Y", key: testPubKey(t, func(x, y *big.Int) bool { return short(y) })},
+	}
+
+	for _, k := range keys {
+		padded := make([]byte, legacyPubKeyLength)
+		k.key.X.FillBytes(padded[:coordinateLength])
+		k.key.Y.FillBytes(padded[coordinateLength:])
+
+		encodings := []struct {
+			name string
+			data []byte
+		}{
+			{name: "compressed", data: MarshalPubKey(k.key)},
+			{name: "legacy", data: LegacyPubKey(k.key)},
+			{name: "padded legacy", data: padded},
+		}
+
+		for _, e := range encodings {
+			t.Run(k.name+"/"+e.name, func(t *testing.T) {
+				got, err := ParsePubKey(e.data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.X.Cmp(k.key.X) != 0 || got.Y.Cmp(k.key.Y) != 0 {
+					t.Errorf("ParsePubKey(%x) = (%x, %x), want (%x, %x)", e.data, got.X, got.Y, k.key.X, k.key.Y)
+				}
+			})
+		}
+	}
+}
+
+func TestParseBadPubKey(t *testing.T) {
+	key := testPubKey(t, func(x, y *big.Int) bool { return true })
+
+	offCurve := LegacyPubKey(key)
+	offCurve[len(offCurve)-1] ^= 1
+
+	badPrefix := MarshalPubKey(key)
+	badPrefix[0] = 0x04
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "point off the curve", data: offCurve},
+		{name: "unknown prefix", data: badPrefix},
+		{name: "too long", data: make([]byte, legacyPubKeyLength+1)},
+		{name: "too short", data: LegacyPubKey(key)[:20]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePubKey(tt.data); !errors.Is(err, ErrBadPubKey) {
+				t.Errorf("ParsePubKey(%x) = %v, want %v", tt.data, err, ErrBadPubKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+)
+
+func (s *Server) handleVersion(payload []byte) error {
+	var msg versionMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	myBestHeight, err := s.bc.GetBestHeight()
+	if err != nil {
+		return err
+	}
+
+	s.addKnownNode(msg.AddrFrom)
+
+	if myBestHeight < msg.BestHeight {
+		s.sendGetBlocks(msg.AddrFrom)
+	} else if myBestHeight > msg.BestHeight {
+		s.sendVersion(msg.AddrFrom)
+	}
+
+	return nil
+}
+
+func (s *Server) handleGetBlocks(payload []byte) error {
+	var msg getBlocksMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	s.sendInv(msg.AddrFrom, invBlock, s.bc.GetBlockHashes())
+	return nil
+}
+
+func (s *Server) handleInv(payload []byte) error {
+	var msg invMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	switch msg.Type {
+	case invBlock:
+		// Inventories list the tip first, blocks are requested from the oldest
+		// missing one so that every block arrives after its parent.
+		var missing [][]byte
+		for i := len(msg.Items) - 1; i >= 0; i-- {
+			if !s.bc.HasBlock(msg.Items[i]) {
+				missing = append(missing, msg.Items[i])
+			}
+		}
+
+		if len(missing) == 0 {
+			return nil
+		}
+
+		s.blocksInTransit = missing[1:]
+		s.sendGetData(msg.AddrFrom, invBlock, missing[0])
+	case invTx:
+		for _, txID := range msg.Items {
+			if _, err := s.mempool.Get(txID); err != nil {
+				s.sendGetData(msg.AddrFrom, invTx, txID)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown inventory type %q", msg.Type)
+	}
+
+	return nil
+}
+
+func (s *Server) handleGetData(payload []byte) error {
+	var msg getDataMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	switch msg.Type {
+	case invBlock:
+		block, err := s.bc.GetBlock(msg.ID)
+		if err != nil {
+			return err
+		}
+
+		s.sendBlock(msg.AddrFrom, block)
+	case invTx:
+		tx, err := s.mempool.Get(msg.ID)
+		if err != nil {
+			// Already mined, the peer learns about it with the block.
+			return nil
+		}
+
+		s.sendTx(msg.AddrFrom, tx)
+	default:
+		return fmt.Errorf("unknown inventory type %q", msg.Type)
+	}
+
+	return nil
+}
+
+func (s *Server) handleBlock(payload []byte) error {
+	var msg blockMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	block, err := blockchain.DeserializeBlock(msg.Block)
+	if err != nil {
+		return err
+	}
+
+	tipChanged, err := s.bc.AddBlock(block)
+	if errors.Is(err, blockchain.ErrOrphanBlock) {
+		s.sendGetBlocks(msg.AddrFrom)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Added block %x at height %d", block.Hash, block.Height)
+
+	if len(s.blocksInTransit) > 0 {
+		next := s.blocksInTransit[0]
+		s.blocksInTransit = s.blocksInTransit[1:]
+		s.sendGetData(msg.AddrFrom, invBlock, next)
+
+		return nil
+	}
+
+	if !tipChanged {
+		return nil
+	}
+
+	s.broadcastInv(invBlock, [][]byte{block.Hash}, msg.AddrFrom)
+	return nil
+}
+
+func (s *Server) handleTx(payload []byte) error {
+	var msg txMsg
+	if err := decodePayload(payload, &msg); err != nil {
+		return err
+	}
+
+	tx, err := transaction.DeserializeTransaction(msg.Transaction)
+	if err != nil {
+		return err
+	}
+
+	err = s.mempool.Add(&tx)
+	if errors.Is(err, blockchain.ErrTxInMempool) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("rejected transaction %x: %w", tx.ID, err)
+	}
+
+	s.broadcastInv(invTx, [][]byte{tx.ID}, msg.AddrFrom)
+
+	if s.MinerAddress != "" {
+		s.requestMining()
+	}
+
+	return nil
+}
+
+// requestMining wakes the miner up, requests made while it is busy are
+// served by one more block.
+func (s *Server) requestMining() {
+	select {
+	case s.mineRequests <- struct{}{}:
+	default:
+	}
+}
+
+// miner mines the mempool on request until the server is closed.
+func (s *Server) miner() {
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-s.mineRequests:
+		}
+
+		if err := s.mineTransactions(); err != nil {
+			log.Printf("ERROR: mining: %v", err)
+		}
+	}
+}
+
+// mineTransactions puts the mempool into a new block and announces it. The
+// proof of work runs unlocked: when a block of a peer extends the chain
+// meanwhile, the mined one is kept as a fork and what is left in the
+// mempool is mined again.
+func (s *Server) mineTransactions() error {
+	s.mu.Lock()
+	block, err := s.mempool.BlockTemplate(s.MinerAddress, 0)
+	s.mu.Unlock()
+	if errors.Is(err, blockchain.ErrEmptyMempool) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = block.Mine(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	tipChanged, err := s.bc.AddBlock(block)
+	if err != nil {
+		return err
+	}
+
+	if !tipChanged {
+		s.requestMining()
+		return nil
+	}
+
+	log.Printf("Mined block %x at height %d", block.Hash, block.Height)
+
+	s.broadcastInv(invBlock, [][]byte{block.Hash}, "")
+	return nil
+}
@@ -0,0 +1,168 @@
+package network
+
+import (
+	"bytes"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+const (
+	protocol      = "tcp"
+	nodeVersion   = 1
+	commandLength = 12
+)
+
+const (
+	cmdVersion   = "version"
+	cmdGetBlocks = "getblocks"
+	cmdInv       = "inv"
+	cmdGetData   = "getdata"
+	cmdBlock     = "block"
+	cmdTx        = "tx"
+)
+
+const (
+	invBlock = "block"
+	invTx    = "tx"
+)
+
+// payload is the body of a message, encoded canonically after the command
+// header: every field in order, integers as uint32, strings and byte strings
+// length-prefixed, lists as a uint32 count followed by their items.
+type payload interface {
+	encode(e *common.Encoder)
+	decode(d *common.Decoder)
+}
+
+type versionMsg struct {
+	AddrFrom   string
+	Version    int
+	BestHeight int
+}
+
+func (m versionMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+	e.PutUint32(uint32(m.Version))
+	e.PutUint32(uint32(m.BestHeight))
+}
+
+func (m *versionMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+	m.Version = int(d.Uint32())
+	m.BestHeight = int(d.Uint32())
+}
+
+type getBlocksMsg struct {
+	AddrFrom string
+}
+
+func (m getBlocksMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+}
+
+func (m *getBlocksMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+}
+
+type invMsg struct {
+	AddrFrom string
+	Type     string
+	Items    [][]byte
+}
+
+func (m invMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+	e.PutBytes([]byte(m.Type))
+	e.PutUint32(uint32(len(m.Items)))
+	for _, item := range m.Items {
+		e.PutBytes(item)
+	}
+}
+
+func (m *invMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+	m.Type = string(d.Bytes())
+	m.Items = make([][]byte, d.Count(4))
+	for i := range m.Items {
+		m.Items[i] = d.Bytes()
+	}
+}
+
+type getDataMsg struct {
+	AddrFrom string
+	Type     string
+	ID       []byte
+}
+
+func (m getDataMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+	e.PutBytes([]byte(m.Type))
+	e.PutBytes(m.ID)
+}
+
+func (m *getDataMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+	m.Type = string(d.Bytes())
+	m.ID = d.Bytes()
+}
+
+// blockMsg carries a block in its canonical encoding, see Block.Serialize.
+type blockMsg struct {
+	AddrFrom string
+	Block    []byte
+}
+
+func (m blockMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+	e.PutBytes(m.Block)
+}
+
+func (m *blockMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+	m.Block = d.Bytes()
+}
+
+// txMsg carries a transaction in its canonical encoding, see
+// Transaction.Serialize.
+type txMsg struct {
+	AddrFrom    string
+	Transaction []byte
+}
+
+func (m txMsg) encode(e *common.Encoder) {
+	e.PutBytes([]byte(m.AddrFrom))
+	e.PutBytes(m.Transaction)
+}
+
+func (m *txMsg) decode(d *common.Decoder) {
+	m.AddrFrom = string(d.Bytes())
+	m.Transaction = d.Bytes()
+}
+
+// commandToBytes pads a command name to the fixed header length.
+func commandToBytes(command string) []byte {
+	var b [commandLength]byte
+	copy(b[:], command)
+
+	return b[:]
+}
+
+func bytesToCommand(b []byte) string {
+	return string(bytes.TrimRight(b, "\x00"))
+}
+
+// newMessage builds a wire message: the command header followed by the
+// canonically encoded payload, versioned like stored records.
+func newMessage(command string, p payload) []byte {
+	e := common.NewEncoder()
+	p.encode(e)
+
+	return append(commandToBytes(command), e.Bytes()...)
+}
+
+func decodePayload(data []byte, p payload) error {
+	d := common.NewDecoder(data)
+	p.decode(d)
+
+	return d.Finish()
+}
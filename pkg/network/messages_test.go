@@ -0,0 +1,90 @@
+package network
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		command string
+		sent    payload
+		got     payload
+	}{
+		{cmdVersion, &versionMsg{AddrFrom: "localhost:3000", Version: nodeVersion, BestHeight: 42}, &versionMsg{}},
+		{cmdGetBlocks, &getBlocksMsg{AddrFrom: "localhost:3000"}, &getBlocksMsg{}},
+		{cmdInv, &invMsg{AddrFrom: "localhost:3000", Type: invBlock, Items: [][]byte{[]byte("first"), {}, []byte("third")}}, &invMsg{}},
+		{cmdInv, &invMsg{AddrFrom: "localhost:3000", Type: invTx, Items: [][]byte{}}, &invMsg{}},
+		{cmdGetData, &getDataMsg{AddrFrom: "localhost:3000", Type: invTx, ID: []byte("id")}, &getDataMsg{}},
+		{cmdBlock, &blockMsg{AddrFrom: "localhost:3000", Block: []byte("block")}, &blockMsg{}},
+		{cmdTx, &txMsg{AddrFrom: "localhost:3000", Transaction: []byte("transaction")}, &txMsg{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			message := newMessage(tt.command, tt.sent)
+			if command := bytesToCommand(message[:commandLength]); command != tt.command {
+				t.Errorf("command %q, want %q", command, tt.command)
+			}
+
+			if err := decodePayload(message[commandLength:], tt.got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.got, tt.sent) {
+				t.Errorf("decodePayload = %+v, want %+v", tt.got, tt.sent)
+			}
+		})
+	}
+}
+
+func TestVersionMessageLayout(t *testing.T) {
+	message := newMessage(cmdVersion, &versionMsg{AddrFrom: "ab", Version: 1, BestHeight: 258})
+
+	want := append(commandToBytes(cmdVersion),
+		0, 0, 0, 1, // encoding version
+		0, 0, 0, 2, 'a', 'b', // address
+		0, 0, 0, 1, // node version
+		0, 0, 1, 2, // best height
+	)
+	if !bytes.Equal(message, want) {
+		t.Errorf("newMessage = %x, want %x", message, want)
+	}
+}
+
+func TestDecodeMalformedPayload(t *testing.T) {
+	data := newMessage(cmdInv, &invMsg{AddrFrom: "localhost:3000", Type: invBlock, Items: [][]byte{[]byte("hash")}})[commandLength:]
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(invMsg{AddrFrom: "localhost:3000", Type: invBlock}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A count of items beyond what the payload can hold.
+	hugeCount := append([]byte{}, data[:len(data)-12]...)
+	hugeCount = append(hugeCount, 0x7f, 0xff, 0xff, 0xff)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: common.ErrTruncated},
+		{name: "truncated", data: data[:len(data)-1], err: common.ErrTruncated},
+		{name: "trailing byte", data: append(append([]byte{}, data...), 0), err: common.ErrTrailingData},
+		{name: "item count beyond the data", data: hugeCount, err: common.ErrTruncated},
+		{name: "gob", data: buf.Bytes(), err: common.ErrUnknownVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := decodePayload(tt.data, &invMsg{}); !errors.Is(err, tt.err) {
+				t.Errorf("decodePayload = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
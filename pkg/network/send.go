@@ -0,0 +1,111 @@
+package network
+
+import (
+	"log"
+	"net"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+)
+
+func sendData(addr string, data []byte) error {
+	conn, err := net.Dial(protocol, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(data)
+	return err
+}
+
+// outgoing is a message queued for a peer.
+type outgoing struct {
+	addr    string
+	command string
+	payload payload
+}
+
+// send queues a message to a peer, delivered once the server is unlocked.
+func (s *Server) send(addr, command string, p payload) {
+	s.outbox = append(s.outbox, outgoing{addr: addr, command: command, payload: p})
+}
+
+// unlock releases the server lock, then delivers the messages queued while
+// it was held and forgets the peers that are not reachable anymore.
+func (s *Server) unlock() {
+	outbox := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+
+	for _, msg := range outbox {
+		if err := sendData(msg.addr, newMessage(msg.command, msg.payload)); err != nil {
+			log.Printf("%s is not available: %v", msg.addr, err)
+
+			s.mu.Lock()
+			s.removeKnownNode(msg.addr)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *Server) sendVersion(addr string) {
+	bestHeight, err := s.bc.GetBestHeight()
+	if err != nil {
+		log.Printf("ERROR: reading best height: %v", err)
+		return
+	}
+
+	s.send(addr, cmdVersion, &versionMsg{AddrFrom: s.Address, Version: nodeVersion, BestHeight: bestHeight})
+}
+
+func (s *Server) sendGetBlocks(addr string) {
+	s.send(addr, cmdGetBlocks, &getBlocksMsg{AddrFrom: s.Address})
+}
+
+func (s *Server) sendInv(addr, kind string, items [][]byte) {
+	s.send(addr, cmdInv, &invMsg{AddrFrom: s.Address, Type: kind, Items: items})
+}
+
+func (s *Server) sendGetData(addr, kind string, id []byte) {
+	s.send(addr, cmdGetData, &getDataMsg{AddrFrom: s.Address, Type: kind, ID: id})
+}
+
+func (s *Server) sendBlock(addr string, b *blockchain.Block) {
+	data, err := b.Serialize()
+	if err != nil {
+		log.Printf("ERROR: serializing block: %v", err)
+		return
+	}
+
+	s.send(addr, cmdBlock, &blockMsg{AddrFrom: s.Address, Block: data})
+}
+
+func (s *Server) sendTx(addr string, tx *transaction.Transaction) {
+	data, err := tx.Serialize()
+	if err != nil {
+		log.Printf("ERROR: serializing transaction: %v", err)
+		return
+	}
+
+	s.send(addr, cmdTx, &txMsg{AddrFrom: s.Address, Transaction: data})
+}
+
+// broadcastInv announces items to every known node except the one they came from.
+func (s *Server) broadcastInv(kind string, items [][]byte, except string) {
+	for _, node := range append([]string{}, s.knownNodes...) {
+		if node != except {
+			s.sendInv(node, kind, items)
+		}
+	}
+}
+
+// SendTx submits a transaction to the node listening on addr.
+func SendTx(addr string, tx *transaction.Transaction) error {
+	serialized, err := tx.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return sendData(addr, newMessage(cmdTx, &txMsg{Transaction: serialized}))
+}
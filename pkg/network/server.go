@@ -0,0 +1,179 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/blockmandu/pkg/blockchain"
+)
+
+// Server is a blockmandu node: it listens on Address, keeps its chain in sync
+// with the known peers and, when MinerAddress is set, mines the transactions
+// it receives.
+//
+// mu guards the chain, the mempool and the peer state. Messages to peers are
+// queued in outbox while it is held and sent once it is released, see unlock,
+// and the proof of work of mined blocks runs without it.
+type Server struct {
+	bc              *blockchain.Blockchain
+	listener        net.Listener
+	mempool         blockchain.Mempool
+	Address         string
+	MinerAddress    string
+	knownNodes      []string
+	blocksInTransit [][]byte
+	outbox          []outgoing
+	mineRequests    chan struct{}
+	quit            chan struct{}
+	mu              sync.Mutex
+}
+
+func NewServer(address, minerAddress string, bc *blockchain.Blockchain, peers []string) *Server {
+	knownNodes := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		if peer != address {
+			knownNodes = append(knownNodes, peer)
+		}
+	}
+
+	return &Server{
+		bc:           bc,
+		mempool:      blockchain.Mempool{Blockchain: bc},
+		Address:      address,
+		MinerAddress: minerAddress,
+		knownNodes:   knownNodes,
+		mineRequests: make(chan struct{}, 1),
+		quit:         make(chan struct{}),
+	}
+}
+
+// Start listens for peers and blocks until the server is closed.
+func (s *Server) Start() error {
+	ln, err := net.Listen(protocol, s.Address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.listener = ln
+	for _, node := range s.knownNodes {
+		s.sendVersion(node)
+	}
+	s.unlock()
+
+	if s.MinerAddress != "" {
+		go s.miner()
+	}
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		go s.handleConnection(conn)
+	}
+}
+
+// Close stops accepting connections and mining.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.quit:
+	default:
+		close(s.quit)
+	}
+
+	if s.listener == nil {
+		return nil
+	}
+
+	return s.listener.Close()
+}
+
+// KnownNodes returns the peers the server currently talks to.
+func (s *Server) KnownNodes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.knownNodes...)
+}
+
+func (s *Server) handleConnection(conn net.Conn) {
+	defer conn.Close()
+
+	request, err := io.ReadAll(conn)
+	if err != nil {
+		log.Printf("ERROR: reading request: %v", err)
+		return
+	}
+
+	if len(request) < commandLength {
+		log.Printf("ERROR: short message from %s", conn.RemoteAddr())
+		return
+	}
+
+	command := bytesToCommand(request[:commandLength])
+	payload := request[commandLength:]
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	switch command {
+	case cmdVersion:
+		err = s.handleVersion(payload)
+	case cmdGetBlocks:
+		err = s.handleGetBlocks(payload)
+	case cmdInv:
+		err = s.handleInv(payload)
+	case cmdGetData:
+		err = s.handleGetData(payload)
+	case cmdBlock:
+		err = s.handleBlock(payload)
+	case cmdTx:
+		err = s.handleTx(payload)
+	default:
+		err = fmt.Errorf("unknown command %q", command)
+	}
+
+	if err != nil {
+		log.Printf("ERROR: handling %s: %v", command, err)
+	}
+}
+
+func (s *Server) isKnown(addr string) bool {
+	for _, node := range s.knownNodes {
+		if node == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) addKnownNode(addr string) {
+	if addr == "" || addr == s.Address || s.isKnown(addr) {
+		return
+	}
+
+	s.knownNodes = append(s.knownNodes, addr)
+}
+
+func (s *Server) removeKnownNode(addr string) {
+	for i, node := range s.knownNodes {
+		if node == addr {
+			s.knownNodes = append(s.knownNodes[:i], s.knownNodes[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package network
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/blockmandu/pkg/blockchain"
+	"github.com/blockmandu/pkg/transaction"
+	"github.com/blockmandu/pkg/wallet"
+)
+
+// TestMain runs the tests in a scratch directory, databases are created
+// under ./resources like the command line does.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "blockmandu-network")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Mkdir(dir+"/resources", 0700); err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestWallet(t *testing.T) (*wallet.Wallet, string) {
+	t.Helper()
+
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, string(w.GetAddress())
+}
+
+// testNodes numbers the node ids of the tests.
+var testNodes int
+
+// newTestChains opens the databases of n nodes, all sharing the genesis
+// block paying address.
+func newTestChains(t *testing.T, address string, n int) []*blockchain.Blockchain {
+	t.Helper()
+
+	var nodeIDs []string
+	for i := 0; i < n; i++ {
+		testNodes++
+		nodeIDs = append(nodeIDs, fmt.Sprintf("test%d", testNodes))
+	}
+
+	genesis, err := blockchain.CreateBlockchain(address, nodeIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = (blockchain.UTXOSet{Blockchain: genesis}).Reindex(); err != nil {
+		t.Fatal(err)
+	}
+	if err = genesis.DB.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(fmt.Sprintf("resources/blockchain_%s.db", nodeIDs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var chains []*blockchain.Blockchain
+	for i, id := range nodeIDs {
+		if i > 0 {
+			if err = os.WriteFile(fmt.Sprintf("resources/blockchain_%s.db", id), data, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		bc, err := blockchain.NewBlockchain(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { bc.DB.Close() })
+
+		chains = append(chains, bc)
+	}
+
+	return chains
+}
+
+// mineBlocks extends a chain with n blocks holding only their coinbase.
+func mineBlocks(t *testing.T, bc *blockchain.Blockchain, address string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		height, err := bc.GetBestHeight()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cbtx, err := transaction.NewCoinbaseTX(address, "", height+1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = bc.MineBlock([]*transaction.Transaction{cbtx}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// startTestNode starts a node on a free localhost port and returns once it
+// accepts connections.
+func startTestNode(t *testing.T, bc *blockchain.Blockchain, miner string, peers ...string) *Server {
+	t.Helper()
+
+	ln, err := net.Listen(protocol, "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	s := NewServer(address, miner, bc, peers)
+	go s.Start()
+	t.Cleanup(func() { s.Close() })
+
+	waitFor(t, "node to listen", func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		return s.listener != nil
+	})
+
+	return s
+}
+
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(20 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (s *Server) tip() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bc.GetBlockHashes()[0]
+}
+
+// waitForTip waits until every node has the given tip.
+func waitForTip(t *testing.T, tip []byte, nodes ...*Server) {
+	t.Helper()
+
+	waitFor(t, fmt.Sprintf("tip %x", tip), func() bool {
+		for _, node := range nodes {
+			if !bytes.Equal(node.tip(), tip) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestNodesConvergeOnMostWork(t *testing.T) {
+	tests := []struct {
+		name string
+		// blocks mined by each node on top of the genesis block before the
+		// nodes connect, the first node is the one the others connect to.
+		blocks []int
+		best   int
+	}{
+		{name: "sync from one node", blocks: []int{3, 0, 0}, best: 0},
+		{name: "connecting node has more work", blocks: []int{1, 3}, best: 1},
+		{name: "connected node has more work", blocks: []int{4, 2}, best: 0},
+		{name: "competing forks", blocks: []int{2, 3, 1}, best: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, address := newTestWallet(t)
+
+			chains := newTestChains(t, address, len(tt.blocks))
+			for j, n := range tt.blocks {
+				mineBlocks(t, chains[j], address, n)
+			}
+			want := chains[tt.best].GetBlockHashes()[0]
+
+			first := startTestNode(t, chains[0], "")
+			nodes := []*Server{first}
+			for _, bc := range chains[1:] {
+				nodes = append(nodes, startTestNode(t, bc, "", first.Address))
+			}
+
+			waitForTip(t, want, nodes...)
+		})
+	}
+}
+
+func TestTransactionIsGossipedMinedAndPropagated(t *testing.T) {
+	sender, senderAddress := newTestWallet(t)
+	_, minerAddress := newTestWallet(t)
+	_, recipient := newTestWallet(t)
+
+	chains := newTestChains(t, senderAddress, 3)
+	miner := startTestNode(t, chains[0], minerAddress)
+	relay := startTestNode(t, chains[1], "", miner.Address)
+	other := startTestNode(t, chains[2], "", miner.Address)
+
+	waitFor(t, "the miner to know its peers", func() bool { return len(miner.KnownNodes()) == 2 })
+
+	// The transaction is built against the relay's chain, which is only read.
+	tx, err := blockchain.NewUnsignedTransaction(senderAddress, recipient, 3, 1, &blockchain.UTXOSet{Blockchain: chains[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = chains[1].SignTransaction(tx, sender.PrivateKey, transaction.SigHashAll); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SendTx(relay.Address, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, "the transaction to be mined", func() bool {
+		miner.mu.Lock()
+		defer miner.mu.Unlock()
+
+		height, err := miner.bc.GetBestHeight()
+		return err == nil && height == 1
+	})
+	waitForTip(t, miner.tip(), relay, other)
+
+	for _, node := range []*Server{miner, relay, other} {
+		node.mu.Lock()
+		count, err := node.mempool.Count()
+		_, findErr := node.bc.FindTransaction(tx.ID)
+		node.mu.Unlock()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 0 {
+			t.Errorf("node %s has %d mempool transaction(s) left", node.Address, count)
+		}
+		if findErr != nil {
+			t.Errorf("node %s misses the transaction: %v", node.Address, findErr)
+		}
+	}
+}
@@ -0,0 +1,439 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxStackSize = 1000
+	// MaxMultiSigKeys bounds the number of keys an OP_CHECKMULTISIG checks.
+	MaxMultiSigKeys = 20
+)
+
+var (
+	ErrEvalFalse             = errors.New("script evaluated to false")
+	ErrNotPushOnly           = errors.New("unlocking script is not push only")
+	ErrStackUnderflow        = errors.New("not enough elements on the stack")
+	ErrStackOverflow         = errors.New("too many elements on the stack")
+	ErrVerifyFailed          = errors.New("verify operation failed")
+	ErrUnbalancedConditional = errors.New("unbalanced conditional")
+	ErrEarlyReturn           = errors.New("script returned early")
+	ErrInvalidOpcode         = errors.New("invalid opcode")
+	ErrCheckerRequired       = errors.New("signature and lock time checks need a checker")
+	ErrNegativeLockTime      = errors.New("negative lock time")
+	ErrUnsatisfiedLockTime   = errors.New("lock time requirement not satisfied")
+	ErrBadMultiSigCount      = errors.New("invalid multisig key or signature count")
+)
+
+// Checker checks what a script cannot see by itself for the input being
+// verified: signatures over the transaction and its lock time.
+type Checker interface {
+	CheckSig(sig, pubKey []byte) (bool, error)
+	CheckLockTime(lockTime int64) (bool, error)
+}
+
+// Verify runs the unlocking script of an input and then the locking script
+// of the output it spends on the resulting stack. The spend is valid when
+// both run without error and leave a true value on top.
+func Verify(unlocking, locking []byte, checker Checker) error {
+	witness, err := PushedData(unlocking)
+	if err != nil {
+		return ErrNotPushOnly
+	}
+
+	return VerifyWitness(witness, locking, checker)
+}
+
+// VerifyWitness runs the locking script of an output on the witness of the
+// input spending it, the stack a push only unlocking script would leave.
+func VerifyWitness(witness [][]byte, locking []byte, checker Checker) error {
+	e := &engine{checker: checker}
+	for _, item := range witness {
+		if err := e.push(item); err != nil {
+			return err
+		}
+	}
+
+	if err := e.execute(locking); err != nil {
+		return err
+	}
+
+	if !e.succeeded() {
+		return ErrEvalFalse
+	}
+
+	if ExtractScriptHash(locking) == nil {
+		return nil
+	}
+
+	// The last item matched the script hash, it now runs as the redeem
+	// script on the items before it.
+	e.stack = append([][]byte{}, witness...)
+	redeem, err := e.pop()
+	if err != nil {
+		return err
+	}
+
+	if err = e.execute(redeem); err != nil {
+		return err
+	}
+
+	if !e.succeeded() {
+		return ErrEvalFalse
+	}
+
+	return nil
+}
+
+type engine struct {
+	checker Checker
+	stack   [][]byte
+	// cond holds, for every enclosing OP_IF, whether its branch executes.
+	cond []bool
+}
+
+func (e *engine) succeeded() bool {
+	return len(e.stack) > 0 && asBool(e.stack[len(e.stack)-1])
+}
+
+func (e *engine) executing() bool {
+	for _, c := range e.cond {
+		if !c {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *engine) push(data []byte) error {
+	if len(data) > maxElementSize {
+		return ErrElementTooBig
+	}
+
+	if len(e.stack) >= maxStackSize {
+		return ErrStackOverflow
+	}
+
+	e.stack = append(e.stack, data)
+	return nil
+}
+
+func (e *engine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+
+	top := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return top, nil
+}
+
+func (e *engine) peek() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, ErrStackUnderflow
+	}
+
+	return e.stack[len(e.stack)-1], nil
+}
+
+func (e *engine) popBool() (bool, error) {
+	top, err := e.pop()
+	if err != nil {
+		return false, err
+	}
+
+	return asBool(top), nil
+}
+
+func (e *engine) execute(script []byte) error {
+	ops, err := Parse(script)
+	if err != nil {
+		return err
+	}
+
+	for _, op := range ops {
+		if err = e.step(op); err != nil {
+			return fmt.Errorf("%s: %w", opName(op.Code), err)
+		}
+	}
+
+	if len(e.cond) != 0 {
+		return ErrUnbalancedConditional
+	}
+
+	return nil
+}
+
+func (e *engine) step(op Op) error {
+	// Conditionals are tracked even inside branches that do not execute.
+	switch op.Code {
+	case OP_IF, OP_NOTIF:
+		branch := false
+		if e.executing() {
+			value, err := e.popBool()
+			if err != nil {
+				return err
+			}
+			branch = value == (op.Code == OP_IF)
+		}
+		e.cond = append(e.cond, branch)
+		return nil
+	case OP_ELSE:
+		if len(e.cond) == 0 {
+			return ErrUnbalancedConditional
+		}
+		e.cond[len(e.cond)-1] = !e.cond[len(e.cond)-1]
+		return nil
+	case OP_ENDIF:
+		if len(e.cond) == 0 {
+			return ErrUnbalancedConditional
+		}
+		e.cond = e.cond[:len(e.cond)-1]
+		return nil
+	}
+
+	if !e.executing() {
+		return nil
+	}
+
+	switch {
+	case op.Code == OP_0:
+		return e.push([]byte{})
+	case op.Code == OP_1NEGATE || isSmallInt(op.Code):
+		return e.push(numberBytes(smallIntValue(op.Code)))
+	case op.IsPush():
+		return e.push(op.Data)
+	}
+
+	switch op.Code {
+	case OP_VERIFY:
+		return e.verify()
+	case OP_RETURN:
+		return ErrEarlyReturn
+	case OP_DROP:
+		_, err := e.pop()
+		return err
+	case OP_DUP:
+		top, err := e.peek()
+		if err != nil {
+			return err
+		}
+		return e.push(top)
+	case OP_SWAP:
+		if len(e.stack) < 2 {
+			return ErrStackUnderflow
+		}
+		n := len(e.stack)
+		e.stack[n-1], e.stack[n-2] = e.stack[n-2], e.stack[n-1]
+		return nil
+	case OP_SIZE:
+		top, err := e.peek()
+		if err != nil {
+			return err
+		}
+		return e.push(numberBytes(int64(len(top))))
+	case OP_EQUAL, OP_EQUALVERIFY:
+		a, err := e.pop()
+		if err != nil {
+			return err
+		}
+		b, err := e.pop()
+		if err != nil {
+			return err
+		}
+		if err = e.push(boolBytes(bytes.Equal(a, b))); err != nil {
+			return err
+		}
+		if op.Code == OP_EQUALVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_SHA256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(top)
+		return e.push(hash[:])
+	case OP_HASH256:
+		top, err := e.pop()
+		if err != nil {
+			return err
+		}
+		return e.push(Hash256(top))
+	case OP_CHECKSIG, OP_CHECKSIGVERIFY:
+		pubKey, err := e.pop()
+		if err != nil {
+			return err
+		}
+		sig, err := e.pop()
+		if err != nil {
+			return err
+		}
+		valid, err := e.checkSig(sig, pubKey)
+		if err != nil {
+			return err
+		}
+		if err = e.push(boolBytes(valid)); err != nil {
+			return err
+		}
+		if op.Code == OP_CHECKSIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	case OP_CHECKLOCKTIMEVERIFY:
+		return e.checkLockTime()
+	case OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY:
+		valid, err := e.checkMultiSig()
+		if err != nil {
+			return err
+		}
+		if err = e.push(boolBytes(valid)); err != nil {
+			return err
+		}
+		if op.Code == OP_CHECKMULTISIGVERIFY {
+			return e.verify()
+		}
+		return nil
+	}
+
+	return ErrInvalidOpcode
+}
+
+func (e *engine) verify() error {
+	ok, err := e.popBool()
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrVerifyFailed
+	}
+
+	return nil
+}
+
+func (e *engine) checkSig(sig, pubKey []byte) (bool, error) {
+	if e.checker == nil {
+		return false, ErrCheckerRequired
+	}
+
+	// An empty signature is a valid way to fail a check, e.g. in an OP_NOTIF.
+	if len(sig) == 0 {
+		return false, nil
+	}
+
+	return e.checker.CheckSig(sig, pubKey)
+}
+
+// checkLockTime fails unless the transaction is locked until at least the
+// height or time on top of the stack, which is left in place.
+func (e *engine) checkLockTime() error {
+	top, err := e.peek()
+	if err != nil {
+		return err
+	}
+
+	lockTime, err := asNumber(top)
+	if err != nil {
+		return err
+	}
+
+	if lockTime < 0 {
+		return ErrNegativeLockTime
+	}
+
+	if e.checker == nil {
+		return ErrCheckerRequired
+	}
+
+	ok, err := e.checker.CheckLockTime(lockTime)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrUnsatisfiedLockTime
+	}
+
+	return nil
+}
+
+// checkMultiSig pops <sig1>..<sigM> M <key1>..<keyN> N and reports whether
+// every signature matches one of the keys. Signatures must appear in the
+// order of their keys, so each key is tried at most once.
+func (e *engine) checkMultiSig() (bool, error) {
+	keys, err := e.popCounted(MaxMultiSigKeys)
+	if err != nil {
+		return false, err
+	}
+
+	sigs, err := e.popCounted(len(keys))
+	if err != nil {
+		return false, err
+	}
+
+	k := 0
+	for _, sig := range sigs {
+		matched := false
+		for ; k < len(keys) && !matched; k++ {
+			if matched, err = e.checkSig(sig, keys[k]); err != nil {
+				return false, err
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// popCounted pops a count of at most max followed by that many elements,
+// returned in the order they were pushed.
+func (e *engine) popCounted(max int) ([][]byte, error) {
+	top, err := e.pop()
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := asNumber(top)
+	if err != nil {
+		return nil, err
+	}
+
+	if count < 0 || count > int64(max) {
+		return nil, ErrBadMultiSigCount
+	}
+
+	if int64(len(e.stack)) < count {
+		return nil, ErrStackUnderflow
+	}
+
+	n := len(e.stack) - int(count)
+	items := append([][]byte{}, e.stack[n:]...)
+	e.stack = e.stack[:n]
+
+	return items, nil
+}
+
+// Hash256 is the double SHA-256 computed by OP_HASH256.
+func Hash256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+
+	return second[:]
+}
+
+func boolBytes(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+
+	return []byte{}
+}
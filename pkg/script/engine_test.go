@@ -0,0 +1,154 @@
+package script
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// testChecker accepts the signature "sig:<pubKey>" for a key and lock times
+// up to lockTime.
+type testChecker struct {
+	lockTime int64
+}
+
+func (c testChecker) CheckSig(sig, pubKey []byte) (bool, error) {
+	return bytes.Equal(sig, testSig(pubKey)), nil
+}
+
+func (c testChecker) CheckLockTime(lockTime int64) (bool, error) {
+	return lockTime <= c.lockTime, nil
+}
+
+func testSig(pubKey []byte) []byte {
+	return append([]byte("sig:"), pubKey...)
+}
+
+func TestVerifyWitness(t *testing.T) {
+	alice, bob, carol := []byte("alice key"), []byte("bob key"), []byte("carol key")
+
+	multiSig, err := MultiSig(2, [][]byte{alice, bob, carol})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("secret")
+	htlc := HashTimeLock(HTLC{
+		SecretHash:    SecretHash(secret),
+		RecipientHash: Hash256(bob),
+		SenderHash:    Hash256(alice),
+		LockTime:      100,
+	})
+
+	tests := []struct {
+		name     string
+		witness  [][]byte
+		locking  []byte
+		lockTime int64
+		err      error
+	}{
+		{name: "pay to pubkey hash", witness: UnlockPubKeyHash(testSig(alice), alice), locking: PayToPubKeyHash(Hash256(alice))},
+		{name: "pay to pubkey hash with another key", witness: UnlockPubKeyHash(testSig(bob), bob), locking: PayToPubKeyHash(Hash256(alice)), err: ErrVerifyFailed},
+		{name: "pay to pubkey hash with a bad signature", witness: UnlockPubKeyHash([]byte("forged"), alice), locking: PayToPubKeyHash(Hash256(alice)), err: ErrEvalFalse},
+		{name: "pay to pubkey hash without witness", locking: PayToPubKeyHash(Hash256(alice)), err: ErrStackUnderflow},
+
+		{name: "multisig", witness: UnlockMultiSig([][]byte{testSig(alice), testSig(carol)}), locking: multiSig},
+		{name: "multisig signatures out of key order", witness: UnlockMultiSig([][]byte{testSig(carol), testSig(alice)}), locking: multiSig, err: ErrEvalFalse},
+		{name: "multisig signature repeated", witness: UnlockMultiSig([][]byte{testSig(bob), testSig(bob)}), locking: multiSig, err: ErrEvalFalse},
+		{name: "multisig missing a signature", witness: UnlockMultiSig([][]byte{testSig(alice)}), locking: multiSig, err: ErrStackUnderflow},
+
+		{name: "pay to script hash", witness: UnlockScriptHash([][]byte{testSig(bob), testSig(carol)}, multiSig), locking: PayToScriptHash(Hash256(multiSig))},
+		{name: "pay to script hash failing redeem script", witness: UnlockScriptHash([][]byte{testSig(bob), testSig(alice)}, multiSig), locking: PayToScriptHash(Hash256(multiSig)), err: ErrEvalFalse},
+		{name: "pay to script hash with another script", witness: UnlockScriptHash(nil, PayToPubKeyHash(Hash256(alice))), locking: PayToScriptHash(Hash256(multiSig)), err: ErrEvalFalse},
+
+		{name: "contract claimed with the secret", witness: UnlockHTLCClaim(testSig(bob), bob, secret), locking: htlc},
+		{name: "contract claimed with a wrong secret", witness: UnlockHTLCClaim(testSig(bob), bob, []byte("guess")), locking: htlc, err: ErrVerifyFailed},
+		{name: "contract claimed by the sender", witness: UnlockHTLCClaim(testSig(alice), alice, secret), locking: htlc, err: ErrVerifyFailed},
+		{name: "contract refunded after the lock time", witness: UnlockHTLCRefund(testSig(alice), alice), locking: htlc, lockTime: 100},
+		{name: "contract refunded before the lock time", witness: UnlockHTLCRefund(testSig(alice), alice), locking: htlc, lockTime: 99, err: ErrUnsatisfiedLockTime},
+
+		{name: "else branch", locking: NewBuilder().AddOp(OP_0).AddOp(OP_IF).AddOp(OP_0).AddOp(OP_ELSE).AddOp(OP_1).AddOp(OP_ENDIF).Script()},
+		{name: "skipped branches still nest", locking: NewBuilder().AddOp(OP_1).AddOp(OP_0).AddOp(OP_IF).AddOp(OP_IF).AddOp(OP_RETURN).AddOp(OP_ENDIF).AddOp(OP_ENDIF).Script()},
+		{name: "unbalanced conditional", locking: NewBuilder().AddOp(OP_1).AddOp(OP_IF).AddOp(OP_1).Script(), err: ErrUnbalancedConditional},
+		{name: "endif without if", locking: NewBuilder().AddOp(OP_1).AddOp(OP_ENDIF).Script(), err: ErrUnbalancedConditional},
+		{name: "early return", locking: NewBuilder().AddOp(OP_1).AddOp(OP_RETURN).Script(), err: ErrEarlyReturn},
+		{name: "unknown opcode", locking: NewBuilder().AddOp(OP_1).AddOp(0x93).Script(), err: ErrInvalidOpcode},
+		{name: "empty stack", locking: NewBuilder().AddOp(OP_1).AddOp(OP_DROP).Script(), err: ErrEvalFalse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWitness(tt.witness, tt.locking, testChecker{lockTime: tt.lockTime})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("VerifyWitness = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyUnlockingScript(t *testing.T) {
+	key := []byte("key")
+	locking := PayToPubKeyHash(Hash256(key))
+
+	tests := []struct {
+		name      string
+		unlocking []byte
+		err       error
+	}{
+		{name: "push only", unlocking: NewBuilder().AddData(testSig(key)).AddData(key).Script()},
+		{name: "with an operation", unlocking: NewBuilder().AddData(testSig(key)).AddData(key).AddOp(OP_DUP).Script(), err: ErrNotPushOnly},
+		{name: "truncated push", unlocking: []byte{0x05, 'k'}, err: ErrNotPushOnly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.unlocking, locking, testChecker{}); !errors.Is(err, tt.err) {
+				t.Errorf("Verify = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutChecker(t *testing.T) {
+	key := []byte("key")
+
+	err := VerifyWitness(UnlockPubKeyHash(testSig(key), key), PayToPubKeyHash(Hash256(key)), nil)
+	if !errors.Is(err, ErrCheckerRequired) {
+		t.Errorf("VerifyWitness = %v, want %v", err, ErrCheckerRequired)
+	}
+}
+
+func TestNumberEncoding(t *testing.T) {
+	tests := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{n: 0, encoded: []byte{}},
+		{n: 1, encoded: []byte{0x01}},
+		{n: -1, encoded: []byte{0x81}},
+		{n: 127, encoded: []byte{0x7f}},
+		{n: 128, encoded: []byte{0x80, 0x00}},
+		{n: -128, encoded: []byte{0x80, 0x80}},
+		{n: 256, encoded: []byte{0x00, 0x01}},
+		{n: 500000000, encoded: []byte{0x00, 0x65, 0xcd, 0x1d}},
+		{n: -2147483647, encoded: []byte{0xff, 0xff, 0xff, 0xff}},
+	}
+
+	for _, tt := range tests {
+		if got := numberBytes(tt.n); !bytes.Equal(got, tt.encoded) {
+			t.Errorf("numberBytes(%d) = %x, want %x", tt.n, got, tt.encoded)
+		}
+
+		got, err := asNumber(tt.encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.n {
+			t.Errorf("asNumber(%x) = %d, want %d", tt.encoded, got, tt.n)
+		}
+	}
+
+	if _, err := asNumber(make([]byte, maxNumberSize+1)); !errors.Is(err, ErrNumberTooBig) {
+		t.Errorf("asNumber of %d bytes = %v, want %v", maxNumberSize+1, err, ErrNumberTooBig)
+	}
+}
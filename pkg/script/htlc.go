@@ -0,0 +1,84 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// HTLC describes a hash time-locked contract: the recipient can spend with
+// the preimage of SecretHash, the sender once LockTime has passed.
+type HTLC struct {
+	SecretHash    []byte
+	RecipientHash []byte
+	SenderHash    []byte
+	LockTime      int64
+}
+
+// HashTimeLock builds the locking script of a contract:
+//
+//	OP_IF
+//	    OP_SHA256 <secretHash> OP_EQUALVERIFY OP_DUP OP_HASH256 <recipientHash>
+//	OP_ELSE
+//	    <lockTime> OP_CHECKLOCKTIMEVERIFY OP_DROP OP_DUP OP_HASH256 <senderHash>
+//	OP_ENDIF
+//	OP_EQUALVERIFY OP_CHECKSIG
+//
+// The secret is hashed with a single SHA-256 so other chains can check it.
+func HashTimeLock(c HTLC) []byte {
+	return NewBuilder().
+		AddOp(OP_IF).
+		AddOp(OP_SHA256).AddData(c.SecretHash).AddOp(OP_EQUALVERIFY).
+		AddOp(OP_DUP).AddOp(OP_HASH256).AddData(c.RecipientHash).
+		AddOp(OP_ELSE).
+		AddInt(c.LockTime).AddOp(OP_CHECKLOCKTIMEVERIFY).AddOp(OP_DROP).
+		AddOp(OP_DUP).AddOp(OP_HASH256).AddData(c.SenderHash).
+		AddOp(OP_ENDIF).
+		AddOp(OP_EQUALVERIFY).AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// ExtractHashTimeLock returns the contract of a locking script built by
+// HashTimeLock, ok is false for any other script.
+func ExtractHashTimeLock(locking []byte) (c HTLC, ok bool) {
+	ops, err := Parse(locking)
+	if err != nil || len(ops) != 17 {
+		return HTLC{}, false
+	}
+
+	lockTime, err := opNumber(ops[8])
+	if err != nil {
+		return HTLC{}, false
+	}
+
+	c = HTLC{
+		SecretHash:    ops[2].Data,
+		RecipientHash: ops[6].Data,
+		SenderHash:    ops[13].Data,
+		LockTime:      lockTime,
+	}
+
+	// Rebuilding the script checks every opcode of the template at once.
+	if !bytes.Equal(HashTimeLock(c), locking) {
+		return HTLC{}, false
+	}
+
+	return c, true
+}
+
+// UnlockHTLCClaim is the witness spending a contract as its recipient:
+// <sig> <pubKey> <secret> 1.
+func UnlockHTLCClaim(sig, pubKey, secret []byte) [][]byte {
+	return [][]byte{sig, pubKey, secret, numberBytes(1)}
+}
+
+// UnlockHTLCRefund is the witness spending a contract as its sender:
+// <sig> <pubKey> 0.
+func UnlockHTLCRefund(sig, pubKey []byte) [][]byte {
+	return [][]byte{sig, pubKey, numberBytes(0)}
+}
+
+// SecretHash hashes an HTLC secret.
+func SecretHash(secret []byte) []byte {
+	hash := sha256.Sum256(secret)
+	return hash[:]
+}
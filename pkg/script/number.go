@@ -0,0 +1,90 @@
+package script
+
+import "errors"
+
+// maxNumberSize bounds the byte length of numbers taken from the stack.
+const maxNumberSize = 5
+
+var ErrNumberTooBig = errors.New("script number is too large")
+
+// numberBytes encodes n as a minimal little-endian sign-magnitude number,
+// zero is the empty array.
+func numberBytes(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	negative := n < 0
+	magnitude := uint64(n)
+	if negative {
+		magnitude = uint64(-n)
+	}
+
+	var result []byte
+	for magnitude > 0 {
+		result = append(result, byte(magnitude&0xff))
+		magnitude >>= 8
+	}
+
+	// The top bit of the last byte is the sign, add a byte when it is taken.
+	if result[len(result)-1]&0x80 != 0 {
+		extra := byte(0x00)
+		if negative {
+			extra = 0x80
+		}
+		result = append(result, extra)
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+
+	return result
+}
+
+// asNumber decodes a number encoded by numberBytes.
+func asNumber(b []byte) (int64, error) {
+	if len(b) > maxNumberSize {
+		return 0, ErrNumberTooBig
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	var n int64
+	for i, v := range b {
+		n |= int64(v) << (8 * i)
+	}
+
+	if b[len(b)-1]&0x80 != 0 {
+		n &^= int64(0x80) << (8 * (len(b) - 1))
+		return -n, nil
+	}
+
+	return n, nil
+}
+
+// asBool reads a stack element as a condition: empty arrays, zeros and
+// negative zero are false.
+func asBool(b []byte) bool {
+	for i, v := range b {
+		if v != 0 {
+			return !(i == len(b)-1 && v == 0x80)
+		}
+	}
+
+	return false
+}
+
+// opNumber returns the number an operation pushes.
+func opNumber(op Op) (int64, error) {
+	switch {
+	case op.Code == OP_0:
+		return 0, nil
+	case op.Code == OP_1NEGATE || isSmallInt(op.Code):
+		return smallIntValue(op.Code), nil
+	case op.IsPush():
+		return asNumber(op.Data)
+	}
+
+	return 0, ErrInvalidOpcode
+}
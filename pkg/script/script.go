@@ -0,0 +1,205 @@
+package script
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxScriptSize  = 10000
+	maxElementSize = 520
+)
+
+var (
+	ErrMalformedPush  = errors.New("script push runs past the end of the script")
+	ErrScriptTooLarge = errors.New("script is too large")
+	ErrElementTooBig  = errors.New("pushed element is too large")
+)
+
+// Op is a parsed script operation, Data holds what push opcodes push.
+type Op struct {
+	Data []byte
+	Code byte
+}
+
+// IsPush reports whether the operation only pushes data.
+func (op Op) IsPush() bool {
+	return op.Code <= OP_16 && op.Code != 0x50
+}
+
+// Parse splits a script into its operations.
+func Parse(script []byte) ([]Op, error) {
+	if len(script) > maxScriptSize {
+		return nil, ErrScriptTooLarge
+	}
+
+	var ops []Op
+	for i := 0; i < len(script); {
+		code := script[i]
+		i++
+
+		var size int
+		switch {
+		case code > OP_0 && code < OP_PUSHDATA1:
+			size = int(code)
+		case code == OP_PUSHDATA1:
+			if i+1 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[i])
+			i++
+		case code == OP_PUSHDATA2:
+			if i+2 > len(script) {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		default:
+			ops = append(ops, Op{Code: code})
+			continue
+		}
+
+		if i+size > len(script) {
+			return nil, ErrMalformedPush
+		}
+
+		ops = append(ops, Op{Code: code, Data: script[i : i+size]})
+		i += size
+	}
+
+	return ops, nil
+}
+
+// IsPushOnly reports whether a script only pushes data, as unlocking
+// scripts have to.
+func IsPushOnly(script []byte) bool {
+	ops, err := Parse(script)
+	if err != nil {
+		return false
+	}
+
+	for _, op := range ops {
+		if !op.IsPush() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PushedData returns the data pushed by a push only script.
+func PushedData(script []byte) ([][]byte, error) {
+	ops, err := Parse(script)
+	if err != nil {
+		return nil, err
+	}
+
+	var data [][]byte
+	for _, op := range ops {
+		switch {
+		case op.Code == OP_0:
+			data = append(data, []byte{})
+		case op.Code == OP_1NEGATE || isSmallInt(op.Code):
+			data = append(data, numberBytes(smallIntValue(op.Code)))
+		case op.IsPush():
+			data = append(data, op.Data)
+		default:
+			return nil, fmt.Errorf("script is not push only: %s", opName(op.Code))
+		}
+	}
+
+	return data, nil
+}
+
+// Disassemble renders a script in a readable form, pushes as hex.
+func Disassemble(script []byte) string {
+	ops, err := Parse(script)
+	if err != nil {
+		return fmt.Sprintf("[invalid script: %v]", err)
+	}
+
+	parts := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.Data != nil {
+			parts = append(parts, hex.EncodeToString(op.Data))
+		} else {
+			parts = append(parts, opName(op.Code))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func opName(code byte) string {
+	if isSmallInt(code) {
+		return fmt.Sprintf("OP_%d", code-OP_1+1)
+	}
+
+	if name, ok := opcodeNames[code]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("OP_UNKNOWN_%#02x", code)
+}
+
+func smallIntValue(code byte) int64 {
+	if code == OP_1NEGATE {
+		return -1
+	}
+
+	return int64(code-OP_1) + 1
+}
+
+// Builder assembles a script operation by operation.
+type Builder struct {
+	script []byte
+}
+
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) AddOp(code byte) *Builder {
+	b.script = append(b.script, code)
+	return b
+}
+
+// AddData pushes data with the smallest push opcode that fits.
+func (b *Builder) AddData(data []byte) *Builder {
+	switch {
+	case len(data) == 0:
+		b.script = append(b.script, OP_0)
+	case len(data) < OP_PUSHDATA1:
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	default:
+		var size [2]byte
+		binary.LittleEndian.PutUint16(size[:], uint16(len(data)))
+		b.script = append(append(b.script, OP_PUSHDATA2), size[:]...)
+	}
+
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt pushes a number, small ones with their dedicated opcodes.
+func (b *Builder) AddInt(n int64) *Builder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_0)
+	case n == -1:
+		return b.AddOp(OP_1NEGATE)
+	case n >= 1 && n <= 16:
+		return b.AddOp(byte(OP_1 + n - 1))
+	}
+
+	return b.AddData(numberBytes(n))
+}
+
+func (b *Builder) Script() []byte {
+	return append([]byte{}, b.script...)
+}
@@ -0,0 +1,163 @@
+package script
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// PayToPubKeyHash locks an output to the owner of the key hashing to
+// pubKeyHash: OP_DUP OP_HASH256 <pubKeyHash> OP_EQUALVERIFY OP_CHECKSIG.
+func PayToPubKeyHash(pubKeyHash []byte) []byte {
+	return NewBuilder().
+		AddOp(OP_DUP).
+		AddOp(OP_HASH256).
+		AddData(pubKeyHash).
+		AddOp(OP_EQUALVERIFY).
+		AddOp(OP_CHECKSIG).
+		Script()
+}
+
+// UnlockPubKeyHash is the witness spending a pay to pubkey hash output:
+// <sig> <pubKey>.
+func UnlockPubKeyHash(sig, pubKey []byte) [][]byte {
+	return [][]byte{sig, pubKey}
+}
+
+// ExtractPubKeyHash returns the key hash a pay to pubkey hash script locks
+// to, nil for any other script.
+func ExtractPubKeyHash(locking []byte) []byte {
+	ops, err := Parse(locking)
+	if err != nil || len(ops) != 5 {
+		return nil
+	}
+
+	if ops[0].Code != OP_DUP || ops[1].Code != OP_HASH256 || !ops[2].IsPush() ||
+		ops[3].Code != OP_EQUALVERIFY || ops[4].Code != OP_CHECKSIG {
+		return nil
+	}
+
+	return ops[2].Data
+}
+
+// IsPayToPubKeyHash reports whether a locking script is the given pay to
+// pubkey hash script.
+func IsPayToPubKeyHash(locking, pubKeyHash []byte) bool {
+	lockedTo := ExtractPubKeyHash(locking)
+	return lockedTo != nil && bytes.Equal(lockedTo, pubKeyHash)
+}
+
+// MultiSig locks an output to any m of the given keys:
+// m <key1> .. <keyN> n OP_CHECKMULTISIG.
+func MultiSig(m int, pubKeys [][]byte) ([]byte, error) {
+	if len(pubKeys) == 0 || len(pubKeys) > MaxMultiSigKeys || m < 1 || m > len(pubKeys) {
+		return nil, ErrBadMultiSigCount
+	}
+
+	b := NewBuilder().AddInt(int64(m))
+	for _, pubKey := range pubKeys {
+		b.AddData(pubKey)
+	}
+
+	return b.AddInt(int64(len(pubKeys))).AddOp(OP_CHECKMULTISIG).Script(), nil
+}
+
+// UnlockMultiSig is the witness spending a multisig output, sigs ordered
+// like their keys: <sig1> .. <sigM>.
+func UnlockMultiSig(sigs [][]byte) [][]byte {
+	return append([][]byte{}, sigs...)
+}
+
+// ExtractMultiSig returns the threshold and keys of a multisig script, ok is
+// false for any other script.
+func ExtractMultiSig(locking []byte) (m int, pubKeys [][]byte, ok bool) {
+	ops, err := Parse(locking)
+	if err != nil || len(ops) < 4 || ops[len(ops)-1].Code != OP_CHECKMULTISIG {
+		return 0, nil, false
+	}
+
+	first, last := ops[0].Code, ops[len(ops)-2].Code
+	if !isSmallInt(first) || !isSmallInt(last) {
+		return 0, nil, false
+	}
+
+	m, n := int(smallIntValue(first)), int(smallIntValue(last))
+	keyOps := ops[1 : len(ops)-2]
+	if n != len(keyOps) || m > n {
+		return 0, nil, false
+	}
+
+	for _, op := range keyOps {
+		if !op.IsPush() || len(op.Data) == 0 {
+			return 0, nil, false
+		}
+		pubKeys = append(pubKeys, op.Data)
+	}
+
+	return m, pubKeys, true
+}
+
+// PayToScriptHash locks an output to a script known only by its hash:
+// OP_HASH256 <scriptHash> OP_EQUAL. The spender reveals the redeem script as
+// the last push of the unlocking script, and it has to succeed as well.
+func PayToScriptHash(scriptHash []byte) []byte {
+	return NewBuilder().
+		AddOp(OP_HASH256).
+		AddData(scriptHash).
+		AddOp(OP_EQUAL).
+		Script()
+}
+
+// UnlockScriptHash is the witness spending a pay to script hash output:
+// <items..> <redeem>.
+func UnlockScriptHash(items [][]byte, redeem []byte) [][]byte {
+	return append(append([][]byte{}, items...), redeem)
+}
+
+// ExtractScriptHash returns the redeem script hash of a pay to script hash
+// script, nil for any other script.
+func ExtractScriptHash(locking []byte) []byte {
+	ops, err := Parse(locking)
+	if err != nil || len(ops) != 3 {
+		return nil
+	}
+
+	if ops[0].Code != OP_HASH256 || !ops[1].IsPush() || len(ops[1].Data) != sha256.Size ||
+		ops[2].Code != OP_EQUAL {
+		return nil
+	}
+
+	return ops[1].Data
+}
+
+// MaxDataCarrierSize bounds the data a null data output carries.
+const MaxDataCarrierSize = 80
+
+var ErrDataTooLarge = errors.New("data output carries too many bytes")
+
+// NullData builds a provably unspendable output script carrying data:
+// OP_RETURN <data>.
+func NullData(data []byte) ([]byte, error) {
+	if len(data) > MaxDataCarrierSize {
+		return nil, ErrDataTooLarge
+	}
+
+	return NewBuilder().AddOp(OP_RETURN).AddData(data).Script(), nil
+}
+
+// ExtractNullData returns the data of a null data script, ok is false for
+// any other script.
+func ExtractNullData(locking []byte) (data []byte, ok bool) {
+	ops, err := Parse(locking)
+	if err != nil || len(ops) != 2 || ops[0].Code != OP_RETURN || !ops[1].IsPush() {
+		return nil, false
+	}
+
+	return ops[1].Data, true
+}
+
+// IsUnspendable reports whether no unlocking script can satisfy a locking
+// script, as it starts with OP_RETURN. Such outputs are never kept as unspent.
+func IsUnspendable(locking []byte) bool {
+	return len(locking) > 0 && locking[0] == OP_RETURN
+}
@@ -0,0 +1,46 @@
+package transaction
+
+import "errors"
+
+// inputChecker answers the checks scripts make while verifying one input.
+type inputChecker struct {
+	tx       *Transaction
+	prevOuts map[string]TXOutput
+	index    int
+}
+
+// CheckSig verifies a signature followed by its hash type against the digest
+// that type selects.
+func (c inputChecker) CheckSig(sig, pubKey []byte) (bool, error) {
+	if c.tx.legacy {
+		hash, err := c.tx.legacySignatureHash(c.index, c.prevOuts)
+		if err != nil {
+			return false, err
+		}
+		return verifyLegacySignature(pubKey, hash, sig), nil
+	}
+
+	if len(sig) != SignatureLength+1 {
+		return false, nil
+	}
+
+	hash, err := c.tx.SignatureHash(c.index, c.prevOuts, SigHashType(sig[SignatureLength]))
+	if errors.Is(err, ErrBadSigHashType) || errors.Is(err, ErrSigHashSingle) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return verifySignature(pubKey, hash, sig[:SignatureLength]), nil
+}
+
+// CheckLockTime accepts lock times of the same kind as the transaction's,
+// height or time, that it has already reached.
+func (c inputChecker) CheckLockTime(lockTime int64) (bool, error) {
+	if (lockTime < LockTimeThreshold) != (c.tx.LockTime < LockTimeThreshold) {
+		return false, nil
+	}
+
+	return lockTime <= c.tx.LockTime, nil
+}
@@ -0,0 +1,218 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+// newEncodingTestTx returns a transaction with two inputs, witnesses and
+// sequences as asked.
+func newEncodingTestTx(t *testing.T, witness, sequence bool) *Transaction {
+	t.Helper()
+
+	tx := &Transaction{
+		Vin: []TXInput{
+			{Txid: []byte("first spent transaction id"), Vout: 0},
+			{Txid: []byte("second spent transaction id"), Vout: 3},
+		},
+		Vout: []TXOutput{
+			{Value: 4, ScriptPubKey: []byte("first locking script")},
+			{Value: 0, ScriptPubKey: nil},
+		},
+		LockTime: 700,
+	}
+	if witness {
+		tx.Vin[1].Witness = [][]byte{[]byte("signature"), {}, []byte("public key")}
+	}
+	if sequence {
+		tx.Vin[0].Sequence = SequenceReplaceable
+	}
+
+	var err error
+	if tx.ID, err = tx.ComputeID(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx
+}
+
+func TestTransactionRoundTrip(t *testing.T) {
+	coinbase, err := NewCoinbaseTX("1Xr11MgYmdXXmex5GewSx1yHqBruviHxFo3qgnQvjAewgEvM17", "", 7, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		tx      *Transaction
+		version uint32
+	}{
+		{name: "plain", tx: newEncodingTestTx(t, false, false), version: common.EncodingVersion},
+		{name: "witness", tx: newEncodingTestTx(t, true, false), version: witnessVersion},
+		{name: "sequence", tx: newEncodingTestTx(t, false, true), version: sequenceVersion},
+		{name: "witness and sequence", tx: newEncodingTestTx(t, true, true), version: sequenceVersion},
+		{name: "coinbase", tx: coinbase, version: common.EncodingVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.tx.Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if version := binary.BigEndian.Uint32(data); version != tt.version {
+				t.Errorf("encoded with version %d, want %d", version, tt.version)
+			}
+
+			got, err := DeserializeTransaction(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.ID, tt.tx.ID) || len(got.Vin) != len(tt.tx.Vin) || len(got.Vout) != len(tt.tx.Vout) || got.LockTime != tt.tx.LockTime {
+				t.Errorf("DeserializeTransaction = %+v, want %+v", got, *tt.tx)
+			}
+
+			again, err := got.Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(again) != string(data) {
+				t.Error("encoding changed after a round trip")
+			}
+		})
+	}
+}
+
+func TestDeserializeMalformedTransaction(t *testing.T) {
+	plain, err := newEncodingTestTx(t, false, false).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withSequence, err := newEncodingTestTx(t, false, true).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The plain encoding as version 2, with an empty witness per input.
+	emptyWitness := append(append([]byte{}, plain...), make([]byte, 8)...)
+	binary.BigEndian.PutUint32(emptyWitness, witnessVersion)
+
+	// The sequence of the first input sits after the version, the input
+	// count, its txid and its vout.
+	emptySequences := append([]byte{}, withSequence...)
+	offset := 4 + 4 + 4 + len("first spent transaction id") + 4
+	binary.BigEndian.PutUint32(emptySequences[offset:], 0)
+
+	unknownVersion := append([]byte{}, plain...)
+	binary.BigEndian.PutUint32(unknownVersion, sequenceVersion+1)
+
+	hugeCount := append([]byte{}, plain...)
+	binary.BigEndian.PutUint32(hugeCount[4:], 1<<30)
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty", data: nil, err: common.ErrTruncated},
+		{name: "truncated", data: plain[:len(plain)-1], err: common.ErrTruncated},
+		{name: "trailing byte", data: append(append([]byte{}, plain...), 0), err: common.ErrTrailingData},
+		{name: "unknown version", data: unknownVersion, err: common.ErrUnknownVersion},
+		{name: "input count beyond the data", data: hugeCount, err: common.ErrTruncated},
+		{name: "witness version without witnesses", data: emptyWitness, err: ErrEmptyWitness},
+		{name: "sequence version without sequences", data: emptySequences, err: ErrEmptySequences},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DeserializeTransaction(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("DeserializeTransaction = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDeserializeGobTransaction(t *testing.T) {
+	_, locking := newTestKey(t)
+	tx, _ := newTestSpend(t, locking, 5)
+	tx.ID = []byte("bogus id")
+	tx.legacy = true
+
+	data, err := tx.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !common.IsLegacyEncoding(data) {
+		t.Fatal("legacy transaction not encoded with gob")
+	}
+
+	if _, err = DeserializeTransaction(data); !errors.Is(err, common.ErrLegacyEncoding) {
+		t.Errorf("DeserializeTransaction = %v, want %v", err, common.ErrLegacyEncoding)
+	}
+
+	stored, err := DeserializeStoredTransaction(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.legacy || string(stored.ID) != "bogus id" {
+		t.Errorf("stored transaction decoded as legacy %v with ID %q", stored.legacy, stored.ID)
+	}
+}
+
+func TestOutputsRoundTrip(t *testing.T) {
+	outputs := NewTXOutputs()
+	outputs.Outputs[0] = TXOutput{Value: 5, ScriptPubKey: []byte("locking script")}
+	outputs.Outputs[7] = TXOutput{Value: 1, ScriptPubKey: []byte{}}
+	outputs.Outputs[2] = TXOutput{Value: 0, ScriptPubKey: []byte("another one")}
+
+	data, err := outputs.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DeserializeOutputs(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, outputs) {
+		t.Errorf("DeserializeOutputs = %+v, want %+v", got, outputs)
+	}
+}
+
+func TestDeserializeMalformedOutputs(t *testing.T) {
+	encode := func(indexes ...uint32) []byte {
+		e := common.NewEncoder()
+		e.PutUint32(uint32(len(indexes)))
+		for _, index := range indexes {
+			e.PutUint32(index)
+			TXOutput{Value: 1, ScriptPubKey: []byte("locking script")}.Encode(e)
+		}
+		return e.Bytes()
+	}
+
+	sorted := encode(1, 4)
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "increasing indexes", data: sorted},
+		{name: "decreasing indexes", data: encode(4, 1), err: ErrUnsortedOutputs},
+		{name: "repeated index", data: encode(4, 4), err: ErrUnsortedOutputs},
+		{name: "truncated", data: sorted[:len(sorted)-1], err: common.ErrTruncated},
+		{name: "trailing byte", data: append(append([]byte{}, sorted...), 0), err: common.ErrTrailingData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DeserializeOutputs(tt.data); !errors.Is(err, tt.err) {
+				t.Errorf("DeserializeOutputs = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
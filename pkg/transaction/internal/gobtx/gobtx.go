@@ -0,0 +1,25 @@
+// Package gobtx freezes the shape transactions had when they were stored with
+// gob. gob writes the field list of a type, and the package qualified names
+// of slice types, with every value, so legacy transactions are encoded
+// through these types to keep their bytes, and so their hashes, whatever
+// fields the transaction types gain. The package is named transaction for
+// the names to match.
+package transaction
+
+type Transaction struct {
+	ID       []byte
+	Vin      []TXInput
+	Vout     []TXOutput
+	LockTime int64
+}
+
+type TXInput struct {
+	Txid      []byte
+	ScriptSig []byte
+	Vout      int
+}
+
+type TXOutput struct {
+	ScriptPubKey []byte
+	Value        int
+}
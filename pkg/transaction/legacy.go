@@ -0,0 +1,100 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/blockmandu/pkg/script"
+	gobtx "github.com/blockmandu/pkg/transaction/internal/gobtx"
+)
+
+// Transactions, outputs and blocks used to be stored with gob. Those records
+// are still read, and legacy transactions keep being hashed over their gob
+// encoding so the chain they are part of stays valid.
+
+func init() {
+	// gob numbers types in the order a process first encodes them: encoding
+	// the transaction types before anything else makes every process
+	// serialize legacy transactions alike.
+	if _, err := (&Transaction{legacy: true}).Serialize(); err != nil {
+		panic(err)
+	}
+}
+
+// MarkLegacy flags transactions decoded with gob as part of a legacy record.
+func MarkLegacy(txs []*Transaction) {
+	for _, tx := range txs {
+		tx.legacy = true
+	}
+}
+
+func (tx *Transaction) serializeLegacy() ([]byte, error) {
+	frozen := gobtx.Transaction{ID: tx.ID, LockTime: tx.LockTime}
+	for _, vin := range tx.Vin {
+		frozen.Vin = append(frozen.Vin, gobtx.TXInput{Txid: vin.Txid, ScriptSig: vin.ScriptSig, Vout: vin.Vout})
+	}
+	for _, out := range tx.Vout {
+		frozen.Vout = append(frozen.Vout, gobtx.TXOutput{ScriptPubKey: out.ScriptPubKey, Value: out.Value})
+	}
+
+	var encoded bytes.Buffer
+
+	encoder := gob.NewEncoder(&encoded)
+	err := encoder.Encode(frozen)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// FirstVersionTx reads the outputs of transactions of the first versions,
+// which paid to a public key hash instead of a locking script, from the gob
+// record a Transaction is decoded from.
+type FirstVersionTx struct {
+	Vout []struct {
+		PubKeyHash []byte
+		Value      int
+	}
+}
+
+// Upgrade locks the outputs of tx paying to a public key hash with the
+// equivalent script.
+func (first FirstVersionTx) Upgrade(tx *Transaction) {
+	for i, out := range first.Vout {
+		if len(out.PubKeyHash) > 0 && len(tx.Vout[i].ScriptPubKey) == 0 {
+			tx.Vout[i].ScriptPubKey = script.PayToPubKeyHash(out.PubKeyHash)
+		}
+	}
+}
+
+func deserializeLegacy(data []byte) (Transaction, error) {
+	var transaction Transaction
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&transaction)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	var first FirstVersionTx
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&first); err != nil {
+		return Transaction{}, err
+	}
+	first.Upgrade(&transaction)
+
+	transaction.legacy = true
+	return transaction, nil
+}
+
+func deserializeLegacyOutputs(data []byte) (TXOutputs, error) {
+	var outputs TXOutputs
+
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	err := dec.Decode(&outputs)
+	if err != nil {
+		return TXOutputs{}, err
+	}
+
+	return outputs, nil
+}
@@ -0,0 +1,86 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+// PartialTransaction is a transaction being signed together with the outputs
+// its inputs spend, everything signing needs, so that keys can sign it on a
+// machine without the chain. Signatures collected so far travel in the input
+// witnesses. Signatures commit to the outputs they spend, a signer given
+// wrong ones only makes signatures the chain rejects.
+type PartialTransaction struct {
+	Tx       *Transaction
+	PrevOuts map[string]TXOutput
+}
+
+// NewPartialTransaction pairs tx with the outputs it spends, which must hold
+// one for every input.
+func NewPartialTransaction(tx *Transaction, prevOuts map[string]TXOutput) (*PartialTransaction, error) {
+	for _, vin := range tx.Vin {
+		if _, ok := prevOuts[OutpointKey(vin.Txid, vin.Vout)]; !ok {
+			return nil, ErrMissingPrevOutput
+		}
+	}
+
+	return &PartialTransaction{Tx: tx, PrevOuts: prevOuts}, nil
+}
+
+// Sign adds the signatures of privKey, see Transaction.Sign.
+func (p *PartialTransaction) Sign(privKey ecdsa.PrivateKey, hashType SigHashType) error {
+	return p.Tx.Sign(privKey, p.PrevOuts, hashType)
+}
+
+// Complete reports whether every input is fully signed.
+func (p *PartialTransaction) Complete() (bool, error) {
+	return p.Tx.Verify(p.PrevOuts)
+}
+
+// Fee returns what the inputs spend beyond the outputs.
+func (p *PartialTransaction) Fee() int {
+	fee := 0
+	for _, vin := range p.Tx.Vin {
+		fee += p.PrevOuts[OutpointKey(vin.Txid, vin.Vout)].Value
+	}
+	for _, out := range p.Tx.Vout {
+		fee -= out.Value
+	}
+
+	return fee
+}
+
+// SerializePrevOuts encodes the spent outputs canonically in the order of
+// the inputs: version uint32, uint32 count, then per output value int64 |
+// script pubkey bytes.
+func (p *PartialTransaction) SerializePrevOuts() []byte {
+	e := common.NewEncoder()
+	e.PutUint32(uint32(len(p.Tx.Vin)))
+	for _, vin := range p.Tx.Vin {
+		p.PrevOuts[OutpointKey(vin.Txid, vin.Vout)].Encode(e)
+	}
+
+	return e.Bytes()
+}
+
+// DeserializePartialTransaction pairs tx with the outputs encoded by
+// SerializePrevOuts.
+func DeserializePartialTransaction(tx *Transaction, prevOutsData []byte) (*PartialTransaction, error) {
+	prevOuts := make(map[string]TXOutput)
+
+	d := common.NewDecoder(prevOutsData)
+	n := d.Count(12)
+	for i := 0; i < n && i < len(tx.Vin); i++ {
+		prevOuts[OutpointKey(tx.Vin[i].Txid, tx.Vin[i].Vout)] = DecodeOutput(d)
+	}
+
+	if err := d.Finish(); err != nil {
+		return nil, err
+	}
+	if n != len(tx.Vin) {
+		return nil, ErrMissingPrevOutput
+	}
+
+	return NewPartialTransaction(tx, prevOuts)
+}
@@ -0,0 +1,53 @@
+package transaction
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPartialTransactionRoundTrip(t *testing.T) {
+	_, locking := newTestKey(t)
+	_, otherLocking := newTestKey(t)
+	tx, prevOuts := newSigHashTestTx(t, locking, otherLocking)
+
+	ptx, err := NewPartialTransaction(tx, prevOuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := ptx.Fee(); fee != 1 {
+		t.Errorf("Fee = %d, want 1", fee)
+	}
+
+	got, err := DeserializePartialTransaction(tx, ptx.SerializePrevOuts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for op, want := range prevOuts {
+		out, ok := got.PrevOuts[op]
+		if !ok || out.Value != want.Value || !bytes.Equal(out.ScriptPubKey, want.ScriptPubKey) {
+			t.Errorf("output %s = %+v, want %+v", op, out, want)
+		}
+	}
+}
+
+func TestPartialTransactionMissingOutputs(t *testing.T) {
+	_, locking := newTestKey(t)
+	tx, prevOuts := newSigHashTestTx(t, locking, locking)
+
+	ptx, err := NewPartialTransaction(tx, prevOuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := ptx.SerializePrevOuts()
+
+	delete(prevOuts, OutpointKey(tx.Vin[1].Txid, tx.Vin[1].Vout))
+	if _, err := NewPartialTransaction(tx, prevOuts); !errors.Is(err, ErrMissingPrevOutput) {
+		t.Errorf("NewPartialTransaction = %v, want %v", err, ErrMissingPrevOutput)
+	}
+
+	tx.Vin = append(tx.Vin, TXInput{Txid: []byte("third"), Vout: 0})
+	if _, err := DeserializePartialTransaction(tx, data); !errors.Is(err, ErrMissingPrevOutput) {
+		t.Errorf("DeserializePartialTransaction = %v, want %v", err, ErrMissingPrevOutput)
+	}
+}
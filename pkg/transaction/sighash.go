@@ -0,0 +1,151 @@
+package transaction
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// SigHashType selects the parts of a transaction a signature covers. It is
+// appended to the signature, so every signature states what it commits to.
+type SigHashType byte
+
+const (
+	// SigHashAll covers every input and output.
+	SigHashAll SigHashType = 0x01
+	// SigHashNone covers the inputs but no output, letting anyone choose
+	// where the coins go.
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle covers the inputs and the output at the signed input's
+	// index only.
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyoneCanPay combines with the others to cover the signed input
+	// alone, so that others can add inputs to the transaction.
+	SigHashAnyoneCanPay SigHashType = 0x80
+
+	sigHashBaseMask = 0x1f
+)
+
+var (
+	ErrBadSigHashType = errors.New("unknown signature hash type")
+	ErrSigHashSingle  = errors.New("SIGHASH_SINGLE input has no output at its index")
+)
+
+var sigHashNames = map[SigHashType]string{SigHashAll: "ALL", SigHashNone: "NONE", SigHashSingle: "SINGLE"}
+
+// ParseSigHashType reads a type written as ALL, NONE or SINGLE, optionally
+// followed by |ANYONECANPAY.
+func ParseSigHashType(s string) (SigHashType, error) {
+	base, anyoneCanPay := strings.CutSuffix(strings.ToUpper(s), "|ANYONECANPAY")
+
+	for hashType, name := range sigHashNames {
+		if name != base {
+			continue
+		}
+		if anyoneCanPay {
+			hashType |= SigHashAnyoneCanPay
+		}
+		return hashType, nil
+	}
+
+	return 0, ErrBadSigHashType
+}
+
+func (t SigHashType) String() string {
+	name, ok := sigHashNames[t.base()]
+	if !ok || t&^(SigHashAnyoneCanPay|sigHashBaseMask) != 0 {
+		return "UNKNOWN"
+	}
+
+	if t&SigHashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+
+	return name
+}
+
+func (t SigHashType) base() SigHashType {
+	return t & sigHashBaseMask
+}
+
+func (t SigHashType) valid() bool {
+	return t.String() != "UNKNOWN"
+}
+
+// SignatureHash returns the digest a signature of input inIdx with the given
+// hash type covers: the transaction without unlocking data, except for the
+// signed input which carries the locking script of the output it spends,
+// trimmed to what the type selects and followed by the value of that output
+// and the type. Committing to the value keeps a signer told a wrong amount,
+// e.g. away from the chain, from signing a fee it did not see.
+func (tx *Transaction) SignatureHash(inIdx int, prevOuts map[string]TXOutput, hashType SigHashType) ([]byte, error) {
+	if !hashType.valid() {
+		return nil, ErrBadSigHashType
+	}
+
+	vin := tx.Vin[inIdx]
+	prevOut, ok := prevOuts[OutpointKey(vin.Txid, vin.Vout)]
+	if !ok {
+		return nil, ErrMissingPrevOutput
+	}
+
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inIdx].ScriptSig = prevOut.ScriptPubKey
+
+	// Without all the outputs signed, the other inputs may update their
+	// sequences.
+	if hashType.base() != SigHashAll {
+		for i := range txCopy.Vin {
+			if i != inIdx {
+				txCopy.Vin[i].Sequence = 0
+			}
+		}
+	}
+
+	switch hashType.base() {
+	case SigHashNone:
+		txCopy.Vout = nil
+	case SigHashSingle:
+		if inIdx >= len(tx.Vout) {
+			return nil, ErrSigHashSingle
+		}
+
+		// Earlier outputs keep their place but none of their content.
+		txCopy.Vout = make([]TXOutput, inIdx+1)
+		for i := range txCopy.Vout[:inIdx] {
+			txCopy.Vout[i] = TXOutput{Value: -1}
+		}
+		txCopy.Vout[inIdx] = tx.Vout[inIdx]
+	}
+
+	if hashType&SigHashAnyoneCanPay != 0 {
+		txCopy.Vin = txCopy.Vin[inIdx : inIdx+1]
+	}
+
+	serialized, err := txCopy.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	var value [8]byte
+	binary.BigEndian.PutUint64(value[:], uint64(prevOut.Value))
+
+	hash := sha256.Sum256(append(append(serialized, value[:]...), byte(hashType)))
+	return hash[:], nil
+}
+
+// legacySignatureHash is the digest legacy transactions signed, which always
+// covered the whole transaction.
+func (tx *Transaction) legacySignatureHash(inIdx int, prevOuts map[string]TXOutput) ([]byte, error) {
+	vin := tx.Vin[inIdx]
+	prevOut, ok := prevOuts[OutpointKey(vin.Txid, vin.Vout)]
+	if !ok {
+		return nil, ErrMissingPrevOutput
+	}
+
+	txCopy := tx.TrimmedCopy()
+	txCopy.Vin[inIdx].ScriptSig = prevOut.ScriptPubKey
+
+	return txCopy.Hash()
+}
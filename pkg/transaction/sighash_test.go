@@ -0,0 +1,165 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+)
+
+func newTestKey(t *testing.T) (ecdsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *key, script.PayToPubKeyHash(common.HashPubKey(common.MarshalPubKey(&key.PublicKey)))
+}
+
+// newSigHashTestTx returns a transaction with two inputs, the first one
+// spending an output locked to locking, and two outputs.
+func newSigHashTestTx(t *testing.T, locking, otherLocking []byte) (*Transaction, map[string]TXOutput) {
+	t.Helper()
+
+	tx := &Transaction{
+		Vin: []TXInput{
+			{Txid: []byte("first spent transaction id"), Vout: 0, Sequence: 1},
+			{Txid: []byte("second spent transaction id"), Vout: 1, Sequence: 1},
+		},
+		Vout: []TXOutput{
+			{Value: 4, ScriptPubKey: otherLocking},
+			{Value: 5, ScriptPubKey: otherLocking},
+		},
+		LockTime: 10,
+	}
+
+	var err error
+	if tx.ID, err = tx.ComputeID(); err != nil {
+		t.Fatal(err)
+	}
+
+	prevOuts := map[string]TXOutput{
+		OutpointKey(tx.Vin[0].Txid, 0): {Value: 6, ScriptPubKey: locking},
+		OutpointKey(tx.Vin[1].Txid, 1): {Value: 4, ScriptPubKey: otherLocking},
+	}
+
+	return tx, prevOuts
+}
+
+func TestSigHashTypes(t *testing.T) {
+	mutations := []struct {
+		name   string
+		mutate func(tx *Transaction, prevOuts map[string]TXOutput)
+	}{
+		{name: "own sequence", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vin[0].Sequence++ }},
+		{name: "spent amount", mutate: func(tx *Transaction, prevOuts map[string]TXOutput) {
+			op := OutpointKey(tx.Vin[0].Txid, tx.Vin[0].Vout)
+			out := prevOuts[op]
+			out.Value++
+			prevOuts[op] = out
+		}},
+		{name: "lock time", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.LockTime++ }},
+		{name: "other input", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vin[1].Vout++ }},
+		{name: "other sequence", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vin[1].Sequence++ }},
+		{name: "added input", mutate: func(tx *Transaction, _ map[string]TXOutput) {
+			tx.Vin = append(tx.Vin, TXInput{Txid: []byte("third"), Vout: 0})
+		}},
+		{name: "output at the input index", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vout[0].Value++ }},
+		{name: "other output", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vout[1].Value++ }},
+		{name: "added output", mutate: func(tx *Transaction, _ map[string]TXOutput) { tx.Vout = append(tx.Vout, TXOutput{Value: 1}) }},
+	}
+
+	// covered lists the mutations invalidating a signature of the first
+	// input, in the order above.
+	tests := []struct {
+		hashType SigHashType
+		covered  []bool
+	}{
+		{hashType: SigHashAll, covered: []bool{true, true, true, true, true, true, true, true, true}},
+		{hashType: SigHashNone, covered: []bool{true, true, true, true, false, true, false, false, false}},
+		{hashType: SigHashSingle, covered: []bool{true, true, true, true, false, true, true, false, false}},
+		{hashType: SigHashAll | SigHashAnyoneCanPay, covered: []bool{true, true, true, false, false, false, true, true, true}},
+		{hashType: SigHashNone | SigHashAnyoneCanPay, covered: []bool{true, true, true, false, false, false, false, false, false}},
+		{hashType: SigHashSingle | SigHashAnyoneCanPay, covered: []bool{true, true, true, false, false, false, true, false, false}},
+	}
+
+	key, locking := newTestKey(t)
+	_, otherLocking := newTestKey(t)
+
+	for _, tt := range tests {
+		for i, m := range mutations {
+			t.Run(tt.hashType.String()+"/"+m.name, func(t *testing.T) {
+				tx, prevOuts := newSigHashTestTx(t, locking, otherLocking)
+				if err := tx.Sign(key, prevOuts, tt.hashType); err != nil {
+					t.Fatal(err)
+				}
+
+				m.mutate(tx, prevOuts)
+
+				checker := inputChecker{tx: tx, prevOuts: prevOuts, index: 0}
+				err := script.VerifyWitness(tx.Vin[0].Witness, locking, checker)
+				if valid := err == nil; valid == tt.covered[i] {
+					t.Errorf("signature valid after the change: %v, want %v", valid, !tt.covered[i])
+				}
+			})
+		}
+	}
+}
+
+func TestSignatureHashErrors(t *testing.T) {
+	_, locking := newTestKey(t)
+	tx, prevOuts := newSigHashTestTx(t, locking, locking)
+	tx.Vout = tx.Vout[:1]
+
+	tests := []struct {
+		name     string
+		inIdx    int
+		hashType SigHashType
+		err      error
+	}{
+		{name: "single with its output", inIdx: 0, hashType: SigHashSingle},
+		{name: "single without output at the input index", inIdx: 1, hashType: SigHashSingle, err: ErrSigHashSingle},
+		{name: "unknown base type", inIdx: 0, hashType: 0x04, err: ErrBadSigHashType},
+		{name: "unknown flag", inIdx: 0, hashType: SigHashAll | 0x40, err: ErrBadSigHashType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tx.SignatureHash(tt.inIdx, prevOuts, tt.hashType); !errors.Is(err, tt.err) {
+				t.Errorf("SignatureHash = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseSigHashType(t *testing.T) {
+	tests := []struct {
+		s    string
+		want SigHashType
+		err  error
+	}{
+		{s: "ALL", want: SigHashAll},
+		{s: "none", want: SigHashNone},
+		{s: "SINGLE|ANYONECANPAY", want: SigHashSingle | SigHashAnyoneCanPay},
+		{s: "all|anyonecanpay", want: SigHashAll | SigHashAnyoneCanPay},
+		{s: "ANYONECANPAY", err: ErrBadSigHashType},
+		{s: "ALL|NONE", err: ErrBadSigHashType},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSigHashType(tt.s)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("ParseSigHashType(%q) = %v, %v, want %v, %v", tt.s, got, err, tt.want, tt.err)
+		}
+		if err == nil && got.String() != strings.ToUpper(tt.s) {
+			t.Errorf("%v prints as %q", got, got.String())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package transaction
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"math/big"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+)
+
+// SignatureLength is the size of a signature before its hash type: R then S,
+// each a 32 byte big-endian field.
+const SignatureLength = 64
+
+var (
+	curveOrder     = elliptic.P256().Params().N
+	halfCurveOrder = new(big.Int).Rsh(curveOrder, 1)
+)
+
+// signHash signs hash with the low S of the two valid ones, (R, S) and
+// (R, N-S), so that only one encoding of a signature verifies.
+func signHash(privKey ecdsa.PrivateKey, hash []byte) ([]byte, error) {
+	r, s, err := ecdsa.Sign(rand.Reader, &privKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Cmp(halfCurveOrder) > 0 {
+		s.Sub(curveOrder, s)
+	}
+
+	sig := make([]byte, SignatureLength)
+	r.FillBytes(sig[:SignatureLength/2])
+	s.FillBytes(sig[SignatureLength/2:])
+
+	return sig, nil
+}
+
+// verifySignature checks a signature made by signHash, rejecting any other
+// encoding of it.
+func verifySignature(pubKey, hash, signature []byte) bool {
+	if len(signature) != SignatureLength {
+		return false
+	}
+
+	key, err := common.ParsePubKey(pubKey)
+	if err != nil {
+		return false
+	}
+
+	r := new(big.Int).SetBytes(signature[:SignatureLength/2])
+	s := new(big.Int).SetBytes(signature[SignatureLength/2:])
+	if r.Sign() == 0 || r.Cmp(curveOrder) >= 0 || s.Sign() == 0 || s.Cmp(halfCurveOrder) > 0 {
+		return false
+	}
+
+	return ecdsa.Verify(key, hash, r, s)
+}
+
+// verifyLegacySignature checks the signatures of legacy transactions, made
+// before signatures had a fixed size: R and S split the bytes in halves, as
+// X and Y do for the key.
+func verifyLegacySignature(pubKey, hash, signature []byte) bool {
+	if len(pubKey) == 0 || len(signature) == 0 {
+		return false
+	}
+
+	r, s := big.Int{}, big.Int{}
+	siglen := len(signature)
+	r.SetBytes(signature[:(siglen / 2)])
+	s.SetBytes(signature[(siglen / 2):])
+
+	x, y := big.Int{}, big.Int{}
+	keylen := len(pubKey)
+	x.SetBytes(pubKey[:(keylen / 2)])
+	y.SetBytes(pubKey[(keylen / 2):])
+
+	rawPubKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
+	return ecdsa.Verify(&rawPubKey, hash, &r, &s)
+}
+
+func hasMultiSigKey(locking, pubKey []byte) bool {
+	_, pubKeys, ok := script.ExtractMultiSig(locking)
+	if !ok {
+		return false
+	}
+
+	for _, key := range pubKeys {
+		if bytes.Equal(key, pubKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// signMultiSig adds the signature of privKey to the ones present for a
+// multisig locking script. Signatures are kept in the order of their keys,
+// as OP_CHECKMULTISIG requires, and extra ones beyond the threshold are
+// dropped.
+func signMultiSig(c inputChecker, privKey ecdsa.PrivateKey, pubKey []byte, hashType SigHashType, present [][]byte, locking []byte) ([][]byte, error) {
+	m, pubKeys, _ := script.ExtractMultiSig(locking)
+
+	slots := make([][]byte, len(pubKeys))
+	for _, sig := range present {
+		for i, key := range pubKeys {
+			if slots[i] != nil {
+				continue
+			}
+
+			valid, err := c.CheckSig(sig, key)
+			if err != nil {
+				return nil, err
+			}
+			if valid {
+				slots[i] = sig
+				break
+			}
+		}
+	}
+
+	for i, key := range pubKeys {
+		if bytes.Equal(key, pubKey) && slots[i] == nil {
+			sig, err := c.tx.SignInput(c.index, privKey, c.prevOuts, hashType)
+			if err != nil {
+				return nil, err
+			}
+			slots[i] = sig
+		}
+	}
+
+	var sigs [][]byte
+	for _, sig := range slots {
+		if sig != nil && len(sigs) < m {
+			sigs = append(sigs, sig)
+		}
+	}
+
+	return sigs, nil
+}
+
+// redeemScript returns the redeem script carried by the unlocking data of an
+// input spending a script hash output, nil if there is none matching.
+func redeemScript(pushes [][]byte, locking []byte) []byte {
+	scriptHash := script.ExtractScriptHash(locking)
+	if scriptHash == nil || len(pushes) == 0 {
+		return nil
+	}
+
+	redeem := pushes[len(pushes)-1]
+	if !bytes.Equal(script.Hash256(redeem), scriptHash) {
+		return nil
+	}
+
+	return redeem
+}
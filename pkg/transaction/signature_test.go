@@ -0,0 +1,136 @@
+package transaction
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"math/big"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+)
+
+// testPrivateKey returns the first key, by private scalar from start, whose
+// public coordinates pass keep.
+func testPrivateKey(t *testing.T, start int64, keep func(x, y *big.Int) bool) ecdsa.PrivateKey {
+	t.Helper()
+
+	curve := elliptic.P256()
+	for d := start; d < start+100000; d++ {
+		x, y := curve.ScalarBaseMult(big.NewInt(d).Bytes())
+		if keep(x, y) {
+			return ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: big.NewInt(d)}
+		}
+	}
+
+	t.Fatal("no key found")
+	return ecdsa.PrivateKey{}
+}
+
+// newTestSpend returns a transaction spending one output of value locked to
+// locking, and the outputs it spends.
+func newTestSpend(t *testing.T, locking []byte, value int) (*Transaction, map[string]TXOutput) {
+	t.Helper()
+
+	prevID := []byte("previous transaction id, 32 byte")
+	tx := &Transaction{
+		Vin:  []TXInput{{Txid: prevID, Vout: 0}},
+		Vout: []TXOutput{{Value: value, ScriptPubKey: locking}},
+	}
+
+	var err error
+	if tx.ID, err = tx.ComputeID(); err != nil {
+		t.Fatal(err)
+	}
+
+	return tx, map[string]TXOutput{OutpointKey(prevID, 0): {Value: value, ScriptPubKey: locking}}
+}
+
+func TestSignLegacyKey(t *testing.T) {
+	full := func(n *big.Int) bool { return len(n.Bytes()) == 32 }
+
+	tests := []struct {
+		name string
+		keep func(x, y *big.Int) bool
+	}{
+		{name: "full coordinates", keep: func(x, y *big.Int) bool { return full(x) && full(y) }},
+		{name: "short X", keep: func(x, y *big.Int) bool { return !full(x) }},
+		{name: "short Y", keep: func(x, y *big.Int) bool { return !full(y) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := testPrivateKey(t, 1, tt.keep)
+
+			// Coins of wallets from before compressed keys are locked to the
+			// hash of their legacy key.
+			locking := script.PayToPubKeyHash(common.HashPubKey(common.LegacyPubKey(&key.PublicKey)))
+			tx, prevOuts := newTestSpend(t, locking, 5)
+
+			if err := tx.Sign(key, prevOuts, SigHashAll); err != nil {
+				t.Fatal(err)
+			}
+
+			verified, err := tx.Verify(prevOuts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verified {
+				t.Error("signature of the legacy key does not verify")
+			}
+		})
+	}
+}
+
+func TestVerifyUnlockingScript(t *testing.T) {
+	key, locking := newTestKey(t)
+
+	tests := []struct {
+		name     string
+		move     bool
+		keep     bool
+		verified bool
+	}{
+		{name: "witness", verified: true},
+		{name: "unlocking script", move: true},
+		{name: "unlocking script and witness", move: true, keep: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, prevOuts := newTestSpend(t, locking, 5)
+			if err := tx.Sign(key, prevOuts, SigHashAll); err != nil {
+				t.Fatal(err)
+			}
+
+			// Unlocking scripts are left out of the ID, so an input moving
+			// its witness there must not verify.
+			if tt.move {
+				builder := script.NewBuilder()
+				for _, item := range tx.Vin[0].Witness {
+					builder.AddData(item)
+				}
+				tx.Vin[0].ScriptSig = builder.Script()
+				if !tt.keep {
+					tx.Vin[0].Witness = nil
+				}
+			}
+
+			verified, err := tx.Verify(prevOuts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verified != tt.verified {
+				t.Errorf("Verify = %v, want %v", verified, tt.verified)
+			}
+			if got := tx.HasUncommittedScriptSig(); got != tt.move {
+				t.Errorf("HasUncommittedScriptSig = %v, want %v", got, tt.move)
+			}
+
+			MarkLegacy([]*Transaction{tx})
+			if tx.HasUncommittedScriptSig() {
+				t.Error("unlocking script of a legacy transaction reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package transaction
+
+// InitialSubsidy is the reward of the blocks before the first halving.
+const InitialSubsidy = 10
+
+// Emission parameters, every node of a network has to use the same values.
+var (
+	// HalvingInterval is the number of blocks after which the subsidy halves.
+	HalvingInterval = 210
+	// MaxSupply caps the coins ever created: no block may push the issued
+	// supply above it, whatever the halving schedule would allow.
+	MaxSupply = 4000
+)
+
+// scheduledSupply sums the halving schedule for blocks 0 to height.
+func scheduledSupply(height int) int {
+	total := 0
+
+	for epoch := 0; epoch*HalvingInterval <= height && epoch < 63; epoch++ {
+		reward := InitialSubsidy >> epoch
+		if reward == 0 {
+			break
+		}
+
+		blocks := HalvingInterval
+		if last := height - epoch*HalvingInterval + 1; last < blocks {
+			blocks = last
+		}
+
+		total += reward * blocks
+	}
+
+	return total
+}
+
+// IssuedSupply returns the coins created by the coinbases of blocks 0 to
+// height, fees excluded.
+func IssuedSupply(height int) int {
+	if height < 0 {
+		return 0
+	}
+
+	supply := scheduledSupply(height)
+	if supply > MaxSupply {
+		return MaxSupply
+	}
+
+	return supply
+}
+
+// Subsidy returns the coins a block at the given height may create.
+func Subsidy(height int) int {
+	return IssuedSupply(height) - IssuedSupply(height-1)
+}
+
+// NextHalving returns the height of the first block after height with a
+// halved subsidy.
+func NextHalving(height int) int {
+	return (height/HalvingInterval + 1) * HalvingInterval
+}
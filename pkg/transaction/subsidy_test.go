@@ -0,0 +1,57 @@
+package transaction
+
+import "testing"
+
+func TestSubsidy(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxSupply int
+		height    int
+		subsidy   int
+		issued    int
+	}{
+		{name: "genesis block", height: 0, subsidy: 10, issued: 10},
+		{name: "last block before the first halving", height: 209, subsidy: 10, issued: 2100},
+		{name: "first halving", height: 210, subsidy: 5, issued: 2105},
+		{name: "second halving rounds down", height: 420, subsidy: 2, issued: 3152},
+		{name: "last block with a subsidy", height: 839, subsidy: 1, issued: 3780},
+		{name: "subsidy runs out", height: 840, subsidy: 0, issued: 3780},
+		{name: "far future", height: 1 << 40, subsidy: 0, issued: 3780},
+		{name: "cap cuts a subsidy short", maxSupply: 2103, height: 210, subsidy: 3, issued: 2103},
+		{name: "nothing after the cap", maxSupply: 2103, height: 211, subsidy: 0, issued: 2103},
+		{name: "cap at a block boundary", maxSupply: 2100, height: 210, subsidy: 0, issued: 2100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxSupply != 0 {
+				defer func(maxSupply int) { MaxSupply = maxSupply }(MaxSupply)
+				MaxSupply = tt.maxSupply
+			}
+
+			if got := Subsidy(tt.height); got != tt.subsidy {
+				t.Errorf("Subsidy(%d) = %d, want %d", tt.height, got, tt.subsidy)
+			}
+			if got := IssuedSupply(tt.height); got != tt.issued {
+				t.Errorf("IssuedSupply(%d) = %d, want %d", tt.height, got, tt.issued)
+			}
+		})
+	}
+}
+
+func TestNextHalving(t *testing.T) {
+	tests := []struct {
+		height, want int
+	}{
+		{height: 0, want: 210},
+		{height: 209, want: 210},
+		{height: 210, want: 420},
+		{height: 1000, want: 1050},
+	}
+
+	for _, tt := range tests {
+		if got := NextHalving(tt.height); got != tt.want {
+			t.Errorf("NextHalving(%d) = %d, want %d", tt.height, got, tt.want)
+		}
+	}
+}
@@ -1,33 +1,76 @@
 package transaction
 
 import (
-	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
-	"encoding/gob"
-	"encoding/hex"
-	"log"
-	"math/big"
+	"errors"
+	"fmt"
+
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
 )
 
-const subsidy = 10
+// LockTimeThreshold separates lock times given as block heights from the ones
+// given as unix times.
+const LockTimeThreshold = 500000000
+
+const (
+	// witnessVersion is the encoding version of transactions carrying
+	// witnesses.
+	witnessVersion = 2
+	// sequenceVersion is the encoding version of transactions with input
+	// sequences, which always has the witness section.
+	sequenceVersion = 3
+)
+
+// SequenceReplaceable is the sequence inputs opt in to replacement with: a
+// transaction with such an input can be replaced in the mempool by one
+// spending the same outputs for a higher fee. Zero opts out.
+const SequenceReplaceable = 1
+
+var (
+	ErrMissingPrevOutput = errors.New("previous output of an input is missing")
+	ErrKeyNotInvolved    = errors.New("key cannot unlock any input of the transaction")
+	ErrEmptyWitness      = errors.New("witness encoding without witnesses")
+	ErrEmptySequences    = errors.New("sequence encoding without sequences")
+	ErrUnsortedOutputs   = errors.New("output indexes are not increasing")
+)
 
 type Transaction struct {
 	ID   []byte
 	Vin  []TXInput
 	Vout []TXOutput
+	// LockTime keeps the transaction out of blocks until the given height or,
+	// from LockTimeThreshold on, the given unix time has passed. Zero means
+	// no lock.
+	LockTime int64
+
+	// legacy marks transactions stored with gob, which keep their gob
+	// encoding, and so their ID, signatures and merkle roots.
+	legacy bool
 }
 
-func NewCoinbaseTX(to, data string) (*Transaction, error) {
+// NewCoinbaseTX creates the transaction rewarding to with the subsidy of the
+// block at the given height plus the fees of the block transactions.
+func NewCoinbaseTX(to, data string, height, fees int) (*Transaction, error) {
 	if data == "" {
-		data = "Reward to '%s'" + to
+		// Random data keeps coinbase IDs unique when a miner is rewarded more than once.
+		randData := make([]byte, 20)
+		if _, err := rand.Read(randData); err != nil {
+			return nil, err
+		}
+
+		data = fmt.Sprintf("Reward to '%s' %x", to, randData)
 	}
 
-	txin := TXInput{[]byte{}, nil, []byte(data), -1}
-	txout := NewTXOutput(subsidy, to)
-	tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
+	txin := TXInput{Txid: []byte{}, ScriptSig: []byte(data), Vout: -1}
+	txout, err := NewTXOutput(Subsidy(height)+fees, to)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := Transaction{ID: nil, Vin: []TXInput{txin}, Vout: []TXOutput{*txout}}
 	txid, err := tx.Hash()
 	if err != nil {
 		return nil, err
@@ -37,6 +80,9 @@ func NewCoinbaseTX(to, data string) (*Transaction, error) {
 	return &tx, nil
 }
 
+// Hash returns the hash of the full transaction encoding, witnesses included.
+// It is the ID of coinbase transactions, and for the others the witness ID
+// blocks commit to next to their IDs.
 func (tx *Transaction) Hash() ([]byte, error) {
 	txCopy := *tx
 	txCopy.ID = []byte{}
@@ -51,97 +97,361 @@ func (tx *Transaction) Hash() ([]byte, error) {
 	return hash[:], nil
 }
 
+// ComputeID returns the ID a transaction must carry: the hash of its content
+// without the witnesses, so that no one relaying the transaction can change
+// its ID by altering signatures. The coinbase input data is kept as it makes
+// coinbase IDs unique.
+func (tx Transaction) ComputeID() ([]byte, error) {
+	if tx.IsCoinbase() {
+		return tx.Hash()
+	}
+
+	txCopy := tx.TrimmedCopy()
+	return txCopy.Hash()
+}
+
+// Serialize encodes the transaction canonically:
+//
+//	version   uint32, common.EncodingVersion
+//	inputs    uint32 count, then per input
+//	          txid bytes | vout int32 | sequence uint32 | script sig bytes
+//	outputs   uint32 count, then per output
+//	          value int64 | script pubkey bytes
+//	lock time int64
+//	witnesses per input, uint32 count then each item as bytes
+//
+// Sequences are only present, and the version is then 3, when an input has
+// one. Otherwise witnesses are only present, and the version is then 2, when
+// an input has one. The ID is not part of the encoding, it is derived with
+// ComputeID.
 func (tx *Transaction) Serialize() ([]byte, error) {
-	var encoded bytes.Buffer
+	if tx.legacy {
+		return tx.serializeLegacy()
+	}
+
+	version := uint32(common.EncodingVersion)
+	switch {
+	case tx.hasSequence():
+		version = sequenceVersion
+	case tx.HasWitness():
+		version = witnessVersion
+	}
+
+	e := common.NewVersionedEncoder(version)
+
+	e.PutUint32(uint32(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		e.PutBytes(vin.Txid)
+		e.PutInt32(int32(vin.Vout))
+		if version == sequenceVersion {
+			e.PutUint32(vin.Sequence)
+		}
+		e.PutBytes(vin.ScriptSig)
+	}
+
+	e.PutUint32(uint32(len(tx.Vout)))
+	for _, out := range tx.Vout {
+		out.Encode(e)
+	}
+
+	e.PutInt64(tx.LockTime)
+
+	if version >= witnessVersion {
+		for _, vin := range tx.Vin {
+			e.PutUint32(uint32(len(vin.Witness)))
+			for _, item := range vin.Witness {
+				e.PutBytes(item)
+			}
+		}
+	}
+
+	return e.Bytes(), nil
+}
+
+// DeserializeStoredTransaction decodes a transaction of a stored block,
+// canonical or, for transactions from before the canonical encoding, gob.
+func DeserializeStoredTransaction(data []byte) (Transaction, error) {
+	if common.IsLegacyEncoding(data) {
+		return deserializeLegacy(data)
+	}
+
+	return DeserializeTransaction(data)
+}
+
+// DeserializeTransaction decodes a canonical transaction. Gob is refused:
+// a gob transaction keeps the ID it comes with and is checked by the rules
+// of legacy transactions, which only stored ones may follow.
+func DeserializeTransaction(data []byte) (Transaction, error) {
+	if common.IsLegacyEncoding(data) {
+		return Transaction{}, common.ErrLegacyEncoding
+	}
+
+	var tx Transaction
+	d, version := common.NewVersionedDecoder(data, sequenceVersion)
+
+	// An input takes at least 12 bytes and an output 12.
+	tx.Vin = make([]TXInput, d.Count(12))
+	for i := range tx.Vin {
+		tx.Vin[i].Txid = d.Bytes()
+		tx.Vin[i].Vout = int(d.Int32())
+		if version == sequenceVersion {
+			tx.Vin[i].Sequence = d.Uint32()
+		}
+		tx.Vin[i].ScriptSig = d.Bytes()
+	}
 
-	encoder := gob.NewEncoder(&encoded)
-	err := encoder.Encode(tx)
+	tx.Vout = make([]TXOutput, d.Count(12))
+	for i := range tx.Vout {
+		tx.Vout[i] = DecodeOutput(d)
+	}
+
+	tx.LockTime = d.Int64()
+
+	if version >= witnessVersion {
+		for i := range tx.Vin {
+			if count := d.Count(4); count > 0 {
+				tx.Vin[i].Witness = make([][]byte, count)
+			}
+			for j := range tx.Vin[i].Witness {
+				tx.Vin[i].Witness[j] = d.Bytes()
+			}
+		}
+	}
+
+	// Every transaction has a single encoding, the lowest version fitting it.
+	if d.Finish() == nil {
+		if version == sequenceVersion && !tx.hasSequence() {
+			return Transaction{}, ErrEmptySequences
+		}
+		if version == witnessVersion && !tx.HasWitness() {
+			return Transaction{}, ErrEmptyWitness
+		}
+	}
+
+	if err := d.Finish(); err != nil {
+		return Transaction{}, err
+	}
+
+	id, err := tx.ComputeID()
 	if err != nil {
-		return nil, err
+		return Transaction{}, err
 	}
 
-	return encoded.Bytes(), nil
+	tx.ID = id
+	return tx, nil
 }
 
-func (tx Transaction) IsCoinbase() bool {
-	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
+// HasWitness reports whether any input carries a witness.
+func (tx Transaction) HasWitness() bool {
+	for _, vin := range tx.Vin {
+		if len(vin.Witness) > 0 {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error {
-	if tx.IsCoinbase() {
-		return nil
+// HasUncommittedScriptSig reports whether an input unlocks with a script
+// outside its witness, which only coinbases and legacy transactions may: the
+// ID of later transactions does not commit to unlocking scripts.
+func (tx Transaction) HasUncommittedScriptSig() bool {
+	if tx.legacy || tx.IsCoinbase() {
+		return false
 	}
 
 	for _, vin := range tx.Vin {
-		if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+		if len(vin.ScriptSig) > 0 {
+			return true
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
+	return false
+}
 
-	for inID, vin := range txCopy.Vin {
-		prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txid, err := txCopy.Hash()
-		if err != nil {
-			return err
+func (tx Transaction) hasSequence() bool {
+	for _, vin := range tx.Vin {
+		if vin.Sequence != 0 {
+			return true
 		}
+	}
 
-		txCopy.ID = txid
-		txCopy.Vin[inID].PubKey = nil
+	return false
+}
 
-		r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)
-		if err != nil {
-			return err
+// IsReplaceable reports whether an input opts in to replacement.
+func (tx Transaction) IsReplaceable() bool {
+	for _, vin := range tx.Vin {
+		if vin.Sequence == SequenceReplaceable {
+			return true
 		}
-		signature := append(r.Bytes(), s.Bytes()...)
+	}
 
-		tx.Vin[inID].Signature = signature
+	return false
+}
+
+// SignalReplaceable opts every input in to replacement. It changes the ID,
+// so it is done before signing.
+func (tx *Transaction) SignalReplaceable() error {
+	for i := range tx.Vin {
+		tx.Vin[i].Sequence = SequenceReplaceable
+	}
+
+	id, err := tx.ComputeID()
+	if err != nil {
+		return err
 	}
 
+	tx.ID = id
 	return nil
 }
 
-func (tx Transaction) Verify(prevTxs map[string]Transaction) (bool, error) {
+func (tx Transaction) IsCoinbase() bool {
+	return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
+}
+
+// OutpointKey identifies the output vout of transaction txid as "txid:vout",
+// it keys the previous outputs passed to Sign and Verify.
+func OutpointKey(txid []byte, vout int) string {
+	return fmt.Sprintf("%x:%d", txid, vout)
+}
+
+// IsFinal reports whether the lock time of the transaction allows it in a
+// block at height whose median time past is blockTime.
+func (tx Transaction) IsFinal(height int, blockTime int64) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	if tx.LockTime < LockTimeThreshold {
+		return tx.LockTime < int64(height)
+	}
+
+	return tx.LockTime < blockTime
+}
+
+// SignInput returns the signature of privKey over input inIdx followed by
+// its hash type, for callers building unlocking data Sign does not know about.
+func (tx *Transaction) SignInput(inIdx int, privKey ecdsa.PrivateKey, prevOuts map[string]TXOutput, hashType SigHashType) ([]byte, error) {
+	hash, err := tx.SignatureHash(inIdx, prevOuts, hashType)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := signHash(privKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sig, byte(hashType)), nil
+}
+
+// Sign unlocks the inputs privKey can spend: pay to pubkey hash outputs of
+// the key are signed outright, while for multisig outputs listing the key its
+// signature is added to the ones other signers already put in. Script hash
+// outputs are signed when the input already carries their redeem script.
+// Signatures cover what hashType selects and go into the input witnesses.
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevOuts map[string]TXOutput, hashType SigHashType) error {
 	if tx.IsCoinbase() {
-		return true, nil
+		return nil
 	}
 
-	for _, vin := range tx.Vin {
-		if prevTxs[hex.EncodeToString(vin.Txid)].ID == nil {
-			log.Panic("ERROR: Previous transaction is not correct")
+	// Wallets created before compressed keys lock their coins to the legacy
+	// encoding of the key.
+	pubKeys := [][]byte{common.MarshalPubKey(&privKey.PublicKey), common.LegacyPubKey(&privKey.PublicKey)}
+	signed := 0
+
+	for inID, vin := range tx.Vin {
+		prevOut, ok := prevOuts[OutpointKey(vin.Txid, vin.Vout)]
+		if !ok {
+			return ErrMissingPrevOutput
+		}
+
+		present, err := vin.UnlockingData()
+		if err != nil {
+			return err
+		}
+
+		checker := inputChecker{tx: tx, prevOuts: prevOuts, index: inID}
+		for _, pubKey := range pubKeys {
+			witness, err := unlock(checker, privKey, pubKey, hashType, present, prevOut)
+			if err != nil {
+				return err
+			}
+			if witness == nil {
+				continue
+			}
+
+			tx.Vin[inID].ScriptSig = nil
+			tx.Vin[inID].Witness = witness
+			signed++
+			break
 		}
 	}
 
-	txCopy := tx.TrimmedCopy()
-	curve := elliptic.P256()
+	if signed == 0 {
+		return ErrKeyNotInvolved
+	}
 
-	for inID, vin := range tx.Vin {
-		prevTx := prevTxs[hex.EncodeToString(vin.Txid)]
-		txCopy.Vin[inID].Signature = nil
-		txCopy.Vin[inID].PubKey = prevTx.Vout[vin.Vout].PubKeyHash
-		txid, err := txCopy.Hash()
+	return nil
+}
+
+// unlock returns the witness spending prevOut, the input checked by c, with
+// the signature of privKey added, nil if pubKey cannot unlock it.
+func unlock(c inputChecker, privKey ecdsa.PrivateKey, pubKey []byte, hashType SigHashType, present [][]byte, prevOut TXOutput) ([][]byte, error) {
+	switch {
+	case prevOut.IsLockedWithKey(common.HashPubKey(pubKey)):
+		signature, err := c.tx.SignInput(c.index, privKey, c.prevOuts, hashType)
+		if err != nil {
+			return nil, err
+		}
+		return script.UnlockPubKeyHash(signature, pubKey), nil
+	case hasMultiSigKey(prevOut.ScriptPubKey, pubKey):
+		sigs, err := signMultiSig(c, privKey, pubKey, hashType, present, prevOut.ScriptPubKey)
+		if err != nil {
+			return nil, err
+		}
+		return script.UnlockMultiSig(sigs), nil
+	case hasMultiSigKey(redeemScript(present, prevOut.ScriptPubKey), pubKey):
+		redeem := present[len(present)-1]
+		sigs, err := signMultiSig(c, privKey, pubKey, hashType, present[:len(present)-1], redeem)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
+		return script.UnlockScriptHash(sigs, redeem), nil
+	}
 
-		txCopy.ID = txid
-		txCopy.Vin[inID].PubKey = nil
+	return nil, nil
+}
 
-		r, s := big.Int{}, big.Int{}
-		siglen := len(vin.Signature)
-		r.SetBytes(vin.Signature[:(siglen / 2)])
-		s.SetBytes(vin.Signature[(siglen / 2):])
+// Verify runs the locking script of the output every input spends on the
+// input's witness, or its unlocking script for legacy transactions. Inputs
+// of other transactions carrying an unlocking script fail.
+func (tx Transaction) Verify(prevOuts map[string]TXOutput) (bool, error) {
+	if tx.IsCoinbase() {
+		return true, nil
+	}
 
-		x, y := big.Int{}, big.Int{}
-		keylen := len(vin.PubKey)
-		x.SetBytes(vin.PubKey[:(keylen / 2)])
-		y.SetBytes(vin.PubKey[(keylen / 2):])
+	for inID, vin := range tx.Vin {
+		prevOut, ok := prevOuts[OutpointKey(vin.Txid, vin.Vout)]
+		if !ok {
+			return false, ErrMissingPrevOutput
+		}
 
-		rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y}
-		if !ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) {
+		checker := inputChecker{tx: &tx, prevOuts: prevOuts, index: inID}
+
+		var err error
+		switch {
+		case tx.legacy && len(vin.Witness) == 0:
+			err = script.Verify(vin.ScriptSig, prevOut.ScriptPubKey, checker)
+		case !tx.legacy && len(vin.ScriptSig) == 0:
+			err = script.VerifyWitness(vin.Witness, prevOut.ScriptPubKey, checker)
+		default:
+			// Unlocking data has a single place, or the ID would not cover
+			// everything the witness does not.
+			err = script.ErrNotPushOnly
+		}
+		if err != nil {
 			return false, nil
 		}
 	}
@@ -153,8 +463,8 @@ func (tx Transaction) TrimmedCopy() Transaction {
 	var inputs []TXInput
 
 	for _, vin := range tx.Vin {
-		inputs = append(inputs, TXInput{Txid: vin.Txid, Vout: vin.Vout, Signature: nil, PubKey: nil})
+		inputs = append(inputs, TXInput{Txid: vin.Txid, Vout: vin.Vout, Sequence: vin.Sequence, ScriptSig: nil})
 	}
 
-	return Transaction{ID: tx.ID, Vin: inputs, Vout: tx.Vout}
+	return Transaction{ID: tx.ID, Vin: inputs, Vout: tx.Vout, LockTime: tx.LockTime, legacy: tx.legacy}
 }
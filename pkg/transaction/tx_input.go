@@ -4,15 +4,38 @@ import (
 	"bytes"
 
 	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
 )
 
+// TXInput spends output Vout of transaction Txid. The data unlocking the
+// output, signatures included, is the Witness, which the transaction ID does
+// not commit to. ScriptSig holds the coinbase data, and the unlocking script
+// of transactions from before witnesses. Sequence is SequenceReplaceable for
+// inputs opting in to replacement.
 type TXInput struct {
 	Txid      []byte
-	Signature []byte
-	PubKey    []byte
+	ScriptSig []byte
 	Vout      int
+	Witness   [][]byte
+	Sequence  uint32
 }
 
+// UnlockingData returns the stack the input unlocks its output with.
+func (in *TXInput) UnlockingData() ([][]byte, error) {
+	if len(in.Witness) > 0 {
+		return in.Witness, nil
+	}
+
+	return script.PushedData(in.ScriptSig)
+}
+
+// UsesKey reports whether the input unlocks a pay to pubkey hash output with
+// the key hashing to pubKeyHash.
 func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
-	return bytes.Equal(common.HashPubKey(in.PubKey), pubKeyHash)
+	data, err := in.UnlockingData()
+	if err != nil || len(data) != 2 {
+		return false
+	}
+
+	return bytes.Equal(common.HashPubKey(data[1]), pubKeyHash)
 }
@@ -2,56 +2,170 @@ package transaction
 
 import (
 	"bytes"
-	"encoding/gob"
+	"sort"
 
 	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
 )
 
+// TXOutput holds coins behind a locking script, whoever provides an unlocking
+// script making it evaluate to true can spend them.
 type TXOutput struct {
-	PubKeyHash []byte
-	Value      int
+	ScriptPubKey []byte
+	Value        int
 }
 
-func NewTXOutput(value int, address string) *TXOutput {
-	txout := &TXOutput{Value: value, PubKeyHash: nil}
-	txout.Lock([]byte(address))
+func NewTXOutput(value int, address string) (*TXOutput, error) {
+	txout := &TXOutput{Value: value, ScriptPubKey: nil}
+	if err := txout.Lock([]byte(address)); err != nil {
+		return nil, err
+	}
+
+	return txout, nil
+}
+
+// LockingScript returns the script outputs paying to address are locked with.
+func LockingScript(address string) ([]byte, error) {
+	version, payload, err := common.DecodeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	switch version {
+	case common.MultiSigVersion:
+		if _, _, ok := script.ExtractMultiSig(payload); !ok {
+			return nil, common.ErrBadAddress
+		}
+		return payload, nil
+	case common.ScriptHashVersion:
+		return script.PayToScriptHash(payload), nil
+	default:
+		return script.PayToPubKeyHash(payload), nil
+	}
+}
+
+// ScriptAddress returns the address a standard locking script pays to, the
+// reverse of LockingScript. ok is false for other scripts.
+func ScriptAddress(locking []byte) (address string, ok bool) {
+	if hash := script.ExtractPubKeyHash(locking); hash != nil {
+		return string(common.EncodeAddress(common.PubKeyHashVersion, hash)), true
+	}
+
+	if hash := script.ExtractScriptHash(locking); hash != nil {
+		return string(common.EncodeAddress(common.ScriptHashVersion, hash)), true
+	}
 
-	return txout
+	if _, _, ok := script.ExtractMultiSig(locking); ok {
+		return string(common.EncodeAddress(common.MultiSigVersion, locking)), true
+	}
+
+	return "", false
 }
 
-func (out *TXOutput) Lock(address []byte) {
-	pubKeyHash := common.Base58Decode(address)
-	pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
-	out.PubKeyHash = pubKeyHash
+// Lock locks the output to whoever can spend from address.
+func (out *TXOutput) Lock(address []byte) error {
+	locking, err := LockingScript(string(address))
+	if err != nil {
+		return err
+	}
+
+	out.ScriptPubKey = locking
+	return nil
 }
 
 func (out *TXOutput) IsLockedWithKey(pubKeyHash []byte) bool {
-	return bytes.Equal(out.PubKeyHash, pubKeyHash)
+	return script.IsPayToPubKeyHash(out.ScriptPubKey, pubKeyHash)
 }
 
+// NewDataOutput creates an unspendable output carrying data, its value is
+// burnt.
+func NewDataOutput(data []byte) (*TXOutput, error) {
+	locking, err := script.NullData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TXOutput{ScriptPubKey: locking, Value: 0}, nil
+}
+
+// IsUnspendable reports whether the output can never be spent, such outputs
+// are left out of the UTXO set.
+func (out *TXOutput) IsUnspendable() bool {
+	return script.IsUnspendable(out.ScriptPubKey)
+}
+
+// IsLockedWithScript reports whether the output is locked with exactly the
+// given script.
+func (out *TXOutput) IsLockedWithScript(locking []byte) bool {
+	return bytes.Equal(out.ScriptPubKey, locking)
+}
+
+// TXOutputs holds the unspent outputs of a transaction keyed by their index
+// in the transaction, so that spending one output does not shift the others.
 type TXOutputs struct {
-	Outputs []TXOutput
+	Outputs map[int]TXOutput
 }
 
+func NewTXOutputs() TXOutputs {
+	return TXOutputs{Outputs: make(map[int]TXOutput)}
+}
+
+// Serialize encodes the outputs canonically by increasing index: version
+// uint32, uint32 count, then per output index uint32 | value int64 | script
+// pubkey bytes.
 func (o TXOutputs) Serialize() ([]byte, error) {
-	var buff bytes.Buffer
-	enc := gob.NewEncoder(&buff)
-	err := enc.Encode(o)
-	if err != nil {
-		return nil, err
+	indexes := make([]int, 0, len(o.Outputs))
+	for index := range o.Outputs {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	e := common.NewEncoder()
+	e.PutUint32(uint32(len(indexes)))
+	for _, index := range indexes {
+		out := o.Outputs[index]
+		e.PutUint32(uint32(index))
+		out.Encode(e)
 	}
 
-	return buff.Bytes(), nil
+	return e.Bytes(), nil
 }
 
 func DeserializeOutputs(data []byte) (TXOutputs, error) {
-	var outputs TXOutputs
+	if common.IsLegacyEncoding(data) {
+		return deserializeLegacyOutputs(data)
+	}
 
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	err := dec.Decode(&outputs)
-	if err != nil {
+	outputs := NewTXOutputs()
+	d := common.NewDecoder(data)
+
+	// Increasing indexes keep the encoding of a set of outputs unique.
+	last, sorted := -1, true
+	for n := d.Count(16); n > 0; n-- {
+		index := int(d.Uint32())
+		outputs.Outputs[index] = DecodeOutput(d)
+
+		sorted = sorted && index > last
+		last = index
+	}
+
+	if err := d.Finish(); err != nil {
 		return TXOutputs{}, err
 	}
+	if !sorted {
+		return TXOutputs{}, ErrUnsortedOutputs
+	}
 
 	return outputs, nil
 }
+
+// Encode writes the output as value int64 | script pubkey bytes.
+func (out TXOutput) Encode(e *common.Encoder) {
+	e.PutInt64(int64(out.Value))
+	e.PutBytes(out.ScriptPubKey)
+}
+
+// DecodeOutput reads an output written by Encode.
+func DecodeOutput(d *common.Decoder) TXOutput {
+	return TXOutput{Value: int(d.Int64()), ScriptPubKey: d.Bytes()}
+}
@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// agentSocket is where ServeKey hands the key of the unlocked wallet file to
+// the commands run meanwhile.
+const agentSocket = "resources/wallet.sock"
+
+// Requests a client writes to the agent, as a single byte.
+const (
+	agentGetKey byte = 'k'
+	agentLock   byte = 'l'
+)
+
+// agentTimeout bounds every exchange with the agent.
+const agentTimeout = time.Second
+
+var (
+	ErrAgentRunning = errors.New("wallet is already unlocked by another process")
+	ErrNoAgent      = errors.New("wallet is not unlocked by any process")
+)
+
+// ServeKey keeps the key of the unlocked wallet in memory for timeout, or
+// until Lock, handing it to the commands asking for it over a unix socket
+// only the user can connect to. The key never reaches the disk.
+func (ws *Wallets) ServeKey(timeout time.Duration) error {
+	if ws.Encryption == nil {
+		return ErrNotEncrypted
+	}
+	if ws.key == nil {
+		return ErrWalletLocked
+	}
+
+	if conn, err := net.DialTimeout("unix", agentSocket, agentTimeout); err == nil {
+		conn.Close()
+		return ErrAgentRunning
+	}
+	// A socket nobody listens on is left by an agent that was killed.
+	if err := os.Remove(agentSocket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	listener, err := listenPrivate(agentSocket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(agentSocket)
+	defer listener.Close()
+
+	timer := time.AfterFunc(timeout, func() { listener.Close() })
+	defer timer.Stop()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if ws.serveKey(conn) == agentLock {
+			return nil
+		}
+	}
+}
+
+// listenPrivate listens on a unix socket at path readable by its owner only.
+// The socket is made under another name and moved in place once restricted,
+// so that no one connects before.
+func listenPrivate(path string) (*net.UnixListener, error) {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmp, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+
+	if err = os.Chmod(tmp, 0600); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		listener.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// serveKey answers the request of a client, returning it.
+func (ws *Wallets) serveKey(conn net.Conn) byte {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	request := make([]byte, 1)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return 0
+	}
+
+	if request[0] == agentGetKey {
+		conn.Write(ws.key)
+	}
+
+	return request[0]
+}
+
+// agentKey asks the process serving the key of the wallet for it.
+func agentKey() ([]byte, error) {
+	conn, err := dialAgent(agentGetKey)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	key := make([]byte, 32)
+	if _, err = io.ReadFull(conn, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Lock stops the process serving the key of the wallet, see ServeKey.
+func Lock() error {
+	conn, err := dialAgent(agentLock)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The agent closes the connection as it stops.
+	_, err = io.Copy(io.Discard, conn)
+	return err
+}
+
+func dialAgent(request byte) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", agentSocket, agentTimeout)
+	if err != nil {
+		return nil, ErrNoAgent
+	}
+	conn.SetDeadline(time.Now().Add(agentTimeout))
+
+	if _, err = conn.Write([]byte{request}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,130 @@
+package wallet
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// startTestAgent encrypts a fresh wallet file and serves its key for
+// timeout, returning the address and the result of ServeKey.
+func startTestAgent(t *testing.T, timeout time.Duration) (string, <-chan error) {
+	t.Helper()
+
+	wallets, address := newTestWallets(t)
+	if err := wallets.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- wallets.ServeKey(timeout) }()
+
+	for i := 0; ; i++ {
+		if _, err := agentKey(); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("agent not serving the key")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return address, done
+}
+
+// signsWithoutPrompt reports whether a freshly loaded wallet file signs for
+// address without asking for the passphrase.
+func signsWithoutPrompt(t *testing.T, address string) bool {
+	t.Helper()
+
+	wallets, err := NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	PassphrasePrompt = nil
+	_, err = wallets.SigningWallet(address)
+	if err != nil && !errors.Is(err, ErrWalletLocked) {
+		t.Fatal(err)
+	}
+
+	return err == nil
+}
+
+func TestServeKeyUntilLock(t *testing.T) {
+	address, done := startTestAgent(t, time.Minute)
+
+	if !signsWithoutPrompt(t, address) {
+		t.Error("wallet locked while its key is served")
+	}
+	if info, err := os.Stat(agentSocket); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("agent socket %v, want mode 0600", info)
+	}
+
+	wallets, err := NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = wallets.Unlock("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err = wallets.ServeKey(time.Minute); !errors.Is(err, ErrAgentRunning) {
+		t.Errorf("second ServeKey = %v, want %v", err, ErrAgentRunning)
+	}
+
+	if err = Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if signsWithoutPrompt(t, address) {
+		t.Error("wallet unlocked after Lock")
+	}
+	if err = Lock(); !errors.Is(err, ErrNoAgent) {
+		t.Errorf("Lock without agent = %v, want %v", err, ErrNoAgent)
+	}
+	if _, err = os.Stat(agentSocket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("agent socket left: %v", err)
+	}
+}
+
+func TestServeKeyTimeout(t *testing.T) {
+	address, done := startTestAgent(t, 200*time.Millisecond)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent still running after its timeout")
+	}
+
+	if signsWithoutPrompt(t, address) {
+		t.Error("wallet unlocked after the timeout")
+	}
+}
+
+func TestServeKeyStaleSocket(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	if err := wallets.ServeKey(time.Minute); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("ServeKey of a plain wallet = %v, want %v", err, ErrNotEncrypted)
+	}
+
+	if err := wallets.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(agentSocket, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wallets.ServeKey(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
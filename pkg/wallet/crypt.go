@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"math/big"
+	"os"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// oldUnlockFile is where unlock sessions of earlier versions kept the key of
+// the wallet file, it is removed when found.
+const oldUnlockFile = "resources/wallet.unlock"
+
+// scrypt cost parameters of newly encrypted wallet files.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	ErrWalletLocked     = errors.New("wallet is locked, its passphrase is needed")
+	ErrNotEncrypted     = errors.New("wallet is not encrypted")
+	ErrAlreadyEncrypted = errors.New("wallet is already encrypted")
+	ErrWrongPassphrase  = errors.New("wrong wallet passphrase")
+)
+
+// Encryption holds the private keys and seed of an encrypted wallet file,
+// sealed with AES-GCM under a key scrypt derives from the passphrase. The
+// rest of the file, addresses and public keys, stays readable.
+type Encryption struct {
+	Salt       []byte
+	N, R, P    int
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// secrets is what Encryption seals: the private key of every wallet by
+// address, and the seed.
+type secrets struct {
+	Keys map[string][]byte
+	Seed []byte
+}
+
+// PassphrasePrompt asks for the passphrase of an encrypted wallet file when
+// its private keys or seed are needed and no process serves its key, the
+// command line sets it. The key derived from it is only kept in memory, by
+// the Wallets it unlocks.
+var PassphrasePrompt func() (string, error)
+
+// Locked reports whether the private keys and seed are unavailable because
+// the file is encrypted and has not been unlocked.
+func (ws *Wallets) Locked() bool {
+	return ws.Encryption != nil && ws.key == nil
+}
+
+// Encrypt protects the wallet with passphrase from the next SaveToFile on.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	if ws.Encryption != nil {
+		return ErrAlreadyEncrypted
+	}
+
+	return ws.setPassphrase(passphrase)
+}
+
+// Unlock decrypts the private keys and seed with passphrase.
+func (ws *Wallets) Unlock(passphrase string) error {
+	if ws.Encryption == nil {
+		return ErrNotEncrypted
+	}
+
+	e := ws.Encryption
+	key, err := scrypt.Key([]byte(passphrase), e.Salt, e.N, e.R, e.P, 32)
+	if err != nil {
+		return err
+	}
+
+	return ws.open(key)
+}
+
+// ChangePassphrase encrypts the wallet with a new passphrase, which takes
+// effect with the next SaveToFile.
+func (ws *Wallets) ChangePassphrase(oldPassphrase, newPassphrase string) error {
+	if err := ws.Unlock(oldPassphrase); err != nil {
+		return err
+	}
+
+	return ws.setPassphrase(newPassphrase)
+}
+
+// EnsureUnlocked decrypts a locked wallet with the key a walletpassphrase
+// process serves, or else with the passphrase PassphrasePrompt asks for.
+func (ws *Wallets) EnsureUnlocked() error {
+	if !ws.Locked() {
+		return nil
+	}
+	if key, err := agentKey(); err == nil && ws.open(key) == nil {
+		return nil
+	}
+	if PassphrasePrompt == nil {
+		return ErrWalletLocked
+	}
+
+	passphrase, err := PassphrasePrompt()
+	if err != nil {
+		return err
+	}
+
+	return ws.Unlock(passphrase)
+}
+
+// removeOldUnlockFile deletes the key an unlock session of an earlier version
+// left on disk.
+func removeOldUnlockFile() error {
+	err := os.Remove(oldUnlockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (ws *Wallets) setPassphrase(passphrase string) error {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return err
+	}
+
+	ws.Encryption = &Encryption{Salt: salt, N: scryptN, R: scryptR, P: scryptP}
+	ws.key = key
+
+	return nil
+}
+
+// open decrypts the secrets with key into the wallets.
+func (ws *Wallets) open(key []byte) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := aead.Open(nil, ws.Encryption.Nonce, ws.Encryption.Ciphertext, nil)
+	if err != nil {
+		return ErrWrongPassphrase
+	}
+
+	var s secrets
+	if err = gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&s); err != nil {
+		return err
+	}
+
+	for address, d := range s.Keys {
+		if w, ok := ws.Wallets[address]; ok {
+			w.PrivateKey.D = new(big.Int).SetBytes(d)
+		}
+	}
+
+	ws.Seed = s.Seed
+	ws.key = key
+
+	return nil
+}
+
+// seal encrypts the private keys and seed with the wallet key.
+func (ws *Wallets) seal() error {
+	s := secrets{Keys: make(map[string][]byte), Seed: ws.Seed}
+	for address, w := range ws.Wallets {
+		if w.PrivateKey.D != nil {
+			s.Keys[address] = w.PrivateKey.D.Bytes()
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(s); err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(ws.key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return err
+	}
+
+	ws.Encryption.Nonce = nonce
+	ws.Encryption.Ciphertext = aead.Seal(nil, nonce, buffer.Bytes(), nil)
+
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,179 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests in a scratch directory, the wallet file is
+// resources/wallet.dat like for the command line.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "blockmandu-wallet")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestWallets returns a fresh wallet file with a seed and one address.
+func newTestWallets(t *testing.T) (*Wallets, string) {
+	t.Helper()
+
+	os.RemoveAll("resources")
+	t.Cleanup(func() { PassphrasePrompt = nil })
+
+	wallets, err := NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = wallets.NewSeed(12); err != nil {
+		t.Fatal(err)
+	}
+	address, err := wallets.CreateWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wallets, address
+}
+
+// promptWith makes PassphrasePrompt answer passphrase, counting the prompts.
+func promptWith(passphrase string) *int {
+	prompts := 0
+	PassphrasePrompt = func() (string, error) {
+		prompts++
+		return passphrase, nil
+	}
+
+	return &prompts
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	wallets, address := newTestWallets(t)
+	key := wallets.Wallets[address].PrivateKey.D.Bytes()
+	seed := wallets.Seed
+
+	if err := wallets.Encrypt("correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(walletFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, key) || bytes.Contains(data, seed) {
+		t.Error("wallet file holds the private key or seed in the clear")
+	}
+
+	loaded, err := NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Locked() || loaded.Seed != nil || loaded.Wallets[address].PrivateKey.D != nil {
+		t.Fatal("encrypted wallet file loaded unlocked")
+	}
+	if _, err = loaded.SigningWallet(address); !errors.Is(err, ErrWalletLocked) {
+		t.Errorf("SigningWallet without prompt = %v, want %v", err, ErrWalletLocked)
+	}
+
+	promptWith("wrong horse")
+	if _, err = loaded.SigningWallet(address); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("SigningWallet with a wrong passphrase = %v, want %v", err, ErrWrongPassphrase)
+	}
+
+	prompts := promptWith("correct horse")
+	for i := 0; i < 2; i++ {
+		w, err := loaded.SigningWallet(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(w.PrivateKey.D.Bytes(), key) {
+			t.Error("unlocked private key differs")
+		}
+	}
+	if *prompts != 1 {
+		t.Errorf("asked %d times for the passphrase, want once", *prompts)
+	}
+	if !bytes.Equal(loaded.Seed, seed) {
+		t.Error("unlocked seed differs")
+	}
+}
+
+func TestChangePassphrase(t *testing.T) {
+	wallets, address := newTestWallets(t)
+	if err := wallets.Encrypt("old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewWallets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.ChangePassphrase("new", "newer"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("ChangePassphrase with a wrong passphrase = %v, want %v", err, ErrWrongPassphrase)
+	}
+	if err = loaded.ChangePassphrase("old", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+
+	for passphrase, want := range map[string]error{"old": ErrWrongPassphrase, "new": nil} {
+		reloaded, err := NewWallets()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		promptWith(passphrase)
+		if _, err = reloaded.SigningWallet(address); !errors.Is(err, want) {
+			t.Errorf("SigningWallet with passphrase %q = %v, want %v", passphrase, err, want)
+		}
+	}
+}
+
+func TestEncryptErrors(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+
+	if err := wallets.Unlock("passphrase"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Unlock of a plain wallet = %v, want %v", err, ErrNotEncrypted)
+	}
+	if err := wallets.Encrypt("passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	if err := wallets.Encrypt("passphrase"); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("Encrypt twice = %v, want %v", err, ErrAlreadyEncrypted)
+	}
+}
+
+func TestOldUnlockFileRemoved(t *testing.T) {
+	wallets, _ := newTestWallets(t)
+	if err := wallets.SaveToFile(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldUnlockFile, []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWallets(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(oldUnlockFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("unlock file of an earlier version left: %v", err)
+	}
+}
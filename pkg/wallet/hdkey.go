@@ -0,0 +1,91 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+// hardenedKeyStart is the first hardened child index, whose key can only be
+// derived from the parent private key.
+const hardenedKeyStart = 0x80000000
+
+// masterKeySecret keys the HMAC deriving the master key from a seed, the one
+// SLIP-10 uses for P-256.
+var masterKeySecret = []byte("Nist256p1 seed")
+
+// extendedKey is a private key with the chain code deriving its children, as
+// in BIP32 carried over to P-256 by SLIP-10.
+type extendedKey struct {
+	key       *big.Int
+	chainCode []byte
+}
+
+func newMasterKey(seed []byte) *extendedKey {
+	data := seed
+	for {
+		mac := hmac.New(sha512.New, masterKeySecret)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		key := new(big.Int).SetBytes(sum[:32])
+		if key.Sign() != 0 && key.Cmp(elliptic.P256().Params().N) < 0 {
+			return &extendedKey{key: key, chainCode: sum[32:]}
+		}
+
+		// Out of range keys, which practically never occur, are replaced
+		// by hashing again.
+		data = sum
+	}
+}
+
+// child derives the key at index, a hardened one from index
+// hardenedKeyStart on.
+func (k *extendedKey) child(index uint32) *extendedKey {
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	var data []byte
+	if index >= hardenedKeyStart {
+		data = append([]byte{0}, k.key.FillBytes(make([]byte, 32))...)
+	} else {
+		data = common.MarshalPubKey(&k.privateKey().PublicKey)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	for {
+		mac := hmac.New(sha512.New, k.chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+
+		tweak := new(big.Int).SetBytes(sum[:32])
+		key := new(big.Int).Add(tweak, k.key)
+		key.Mod(key, n)
+		if tweak.Cmp(n) < 0 && key.Sign() != 0 {
+			return &extendedKey{key: key, chainCode: sum[32:]}
+		}
+
+		data = binary.BigEndian.AppendUint32(append([]byte{1}, sum[32:]...), index)
+	}
+}
+
+// derive follows path from k, one child index per level.
+func (k *extendedKey) derive(path ...uint32) *extendedKey {
+	for _, index := range path {
+		k = k.child(index)
+	}
+
+	return k
+}
+
+func (k *extendedKey) privateKey() *ecdsa.PrivateKey {
+	curve := elliptic.P256()
+	x, y := curve.ScalarBaseMult(k.key.FillBytes(make([]byte, 32)))
+
+	return &ecdsa.PrivateKey{D: new(big.Int).Set(k.key), PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}}
+}
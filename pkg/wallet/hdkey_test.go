@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+// The first SLIP-10 test vector for the nist256p1 curve.
+func TestDeriveVectors(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path      string
+		indexes   []uint32
+		chainCode string
+		key       string
+		pubKey    string
+	}{
+		{
+			path:      "m",
+			chainCode: "beeb672fe4621673f722f38529c07392fecaa61015c80c34f29ce8b41b3cb6ea",
+			key:       "612091aaa12e22dd2abef664f8a01a82cae99ad7441b7ef8110424915c268bc2",
+			pubKey:    "0266874dc6ade47b3ecd096745ca09bcd29638dd52c2c12117b11ed3e458cfa9e8",
+		},
+		{
+			path:      "m/0'",
+			indexes:   []uint32{hardenedKeyStart},
+			chainCode: "3460cea53e6a6bb5fb391eeef3237ffd8724bf0a40e94943c98b83825342ee11",
+			key:       "6939694369114c67917a182c59ddb8cafc3004e63ca5d3b84403ba8613debc0c",
+			pubKey:    "0384610f5ecffe8fda089363a41f56a5c7ffc1d81b59a612d0d649b2d22355590c",
+		},
+		{
+			path:      "m/0'/1",
+			indexes:   []uint32{hardenedKeyStart, 1},
+			chainCode: "4187afff1aafa8445010097fb99d23aee9f599450c7bd140b6826ac22ba21d0c",
+			key:       "284e9d38d07d21e4e281b645089a94f4cf5a5a81369acf151a1c3a57f18b2129",
+			pubKey:    "03526c63f8d0b4bbbf9c80df553fe66742df4676b241dabefdef67733e070f6844",
+		},
+		{
+			path:      "m/0'/1/2'",
+			indexes:   []uint32{hardenedKeyStart, 1, hardenedKeyStart + 2},
+			chainCode: "98c7514f562e64e74170cc3cf304ee1ce54d6b6da4f880f313e8204c2a185318",
+			key:       "694596e8a54f252c960eb771a3c41e7e32496d03b954aeb90f61635b8e092aa7",
+			pubKey:    "0359cf160040778a4b14c5f4d7b76e327ccc8c4a6086dd9451b7482b5a4972dda0",
+		},
+	}
+
+	master := newMasterKey(seed)
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			k := master.derive(tt.indexes...)
+
+			if got := hex.EncodeToString(k.chainCode); got != tt.chainCode {
+				t.Errorf("chain code %s, want %s", got, tt.chainCode)
+			}
+			if got := hex.EncodeToString(k.key.FillBytes(make([]byte, 32))); got != tt.key {
+				t.Errorf("key %s, want %s", got, tt.key)
+			}
+			if got := hex.EncodeToString(common.MarshalPubKey(&k.privateKey().PublicKey)); got != tt.pubKey {
+				t.Errorf("public key %s, want %s", got, tt.pubKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// english.txt is the BIP39 English word list.
+//
+//go:embed english.txt
+var englishWords string
+
+var (
+	wordList  = strings.Fields(englishWords)
+	wordIndex = func() map[string]int {
+		index := make(map[string]int, len(wordList))
+		for i, word := range wordList {
+			index[word] = i
+		}
+		return index
+	}()
+)
+
+var (
+	ErrMnemonicLength   = errors.New("mnemonic phrases have 12 or 24 words")
+	ErrUnknownWord      = errors.New("word is not in the mnemonic word list")
+	ErrMnemonicChecksum = errors.New("mnemonic phrase checksum does not match")
+)
+
+// NewMnemonic returns a random BIP39 phrase of 12 or 24 words, encoding 128
+// or 256 bits of entropy followed by the first bits of their SHA-256 as a
+// checksum.
+func NewMnemonic(words int) (string, error) {
+	if words != 12 && words != 24 {
+		return "", ErrMnemonicLength
+	}
+
+	entropy := make([]byte, words*4/3)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+
+	return encodeMnemonic(entropy), nil
+}
+
+func encodeMnemonic(entropy []byte) string {
+	checksumBits := len(entropy) / 4
+	checksum := sha256.Sum256(entropy)
+
+	bits := new(big.Int).SetBytes(entropy)
+	bits.Lsh(bits, uint(checksumBits))
+	bits.Or(bits, big.NewInt(int64(checksum[0]>>(8-checksumBits))))
+
+	words := make([]string, (len(entropy)*8+checksumBits)/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = wordList[new(big.Int).And(bits, mask).Int64()]
+		bits.Rsh(bits, 11)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ValidateMnemonic checks that a phrase is made of known words and that its
+// checksum matches.
+func ValidateMnemonic(mnemonic string) error {
+	words := strings.Fields(mnemonic)
+	if len(words) != 12 && len(words) != 24 {
+		return ErrMnemonicLength
+	}
+
+	bits := new(big.Int)
+	for _, word := range words {
+		i, ok := wordIndex[word]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownWord, word)
+		}
+		bits.Lsh(bits, 11)
+		bits.Or(bits, big.NewInt(int64(i)))
+	}
+
+	checksumBits := len(words) / 3
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1)).Int64()
+	bits.Rsh(bits, uint(checksumBits))
+
+	entropy := bits.FillBytes(make([]byte, checksumBits*4))
+	expected := sha256.Sum256(entropy)
+	if int64(expected[0]>>(8-checksumBits)) != checksum {
+		return ErrMnemonicChecksum
+	}
+
+	return nil
+}
+
+// MnemonicSeed derives the 64 byte seed of a phrase as BIP39 does, with
+// PBKDF2-HMAC-SHA512 salted with an optional passphrase.
+func MnemonicSeed(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
@@ -0,0 +1,95 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Vectors of the BIP39 reference, whose seeds use the passphrase "TREZOR".
+func TestMnemonicVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			seed:     "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+			mnemonic: strings.Repeat("abandon ", 23) + "art",
+			seed:     "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for _, tt := range tests {
+		entropy, err := hex.DecodeString(tt.entropy)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := encodeMnemonic(entropy); got != tt.mnemonic {
+			t.Errorf("encodeMnemonic(%s) = %q, want %q", tt.entropy, got, tt.mnemonic)
+		}
+		if err = ValidateMnemonic(tt.mnemonic); err != nil {
+			t.Errorf("ValidateMnemonic(%q) = %v", tt.mnemonic, err)
+		}
+		if got := hex.EncodeToString(MnemonicSeed(tt.mnemonic, "TREZOR")); got != tt.seed {
+			t.Errorf("MnemonicSeed(%q) = %s, want %s", tt.mnemonic, got, tt.seed)
+		}
+	}
+}
+
+func TestValidateMnemonic(t *testing.T) {
+	tests := []struct {
+		name     string
+		mnemonic string
+		err      error
+	}{
+		{name: "extra spaces", mnemonic: " legal winner thank year wave sausage\tworth useful legal winner thank  yellow\n"},
+		{name: "11 words", mnemonic: strings.Repeat("abandon ", 10) + "about", err: ErrMnemonicLength},
+		{name: "unknown word", mnemonic: strings.Repeat("abandon ", 11) + "abandoned", err: ErrUnknownWord},
+		{name: "bad checksum", mnemonic: strings.Repeat("abandon ", 12), err: ErrMnemonicChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMnemonic(tt.mnemonic); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateMnemonic = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestNewMnemonic(t *testing.T) {
+	for _, words := range []int{12, 24} {
+		mnemonic, err := NewMnemonic(words)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(strings.Fields(mnemonic)); n != words {
+			t.Errorf("phrase of %d words, want %d", n, words)
+		}
+		if err = ValidateMnemonic(mnemonic); err != nil {
+			t.Errorf("ValidateMnemonic(%q) = %v", mnemonic, err)
+		}
+	}
+
+	if _, err := NewMnemonic(18); !errors.Is(err, ErrMnemonicLength) {
+		t.Errorf("NewMnemonic(18) = %v, want %v", err, ErrMnemonicLength)
+	}
+}
@@ -0,0 +1,32 @@
+package wallet
+
+import (
+	common "github.com/blockmandu/pkg/commons"
+	"github.com/blockmandu/pkg/script"
+)
+
+// ScriptHashAddress returns the address paying to a redeem script.
+func ScriptHashAddress(redeem []byte) string {
+	return string(common.EncodeAddress(common.ScriptHashVersion, script.Hash256(redeem)))
+}
+
+// AddMultiSig records the script requiring any m of the given public keys and
+// returns its script hash address. Payers only learn the hash, the script is
+// kept to spend from the address later, so the order of the keys matters.
+func (ws *Wallets) AddMultiSig(m int, pubKeys [][]byte) (string, error) {
+	redeem, err := script.MultiSig(m, pubKeys)
+	if err != nil {
+		return "", err
+	}
+
+	address := ScriptHashAddress(redeem)
+	ws.Scripts[address] = redeem
+
+	return address, nil
+}
+
+// GetScript returns the redeem script behind a script hash address.
+func (ws *Wallets) GetScript(address string) ([]byte, bool) {
+	redeem, ok := ws.Scripts[address]
+	return redeem, ok
+}
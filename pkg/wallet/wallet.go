@@ -12,13 +12,20 @@ import (
 )
 
 const (
-	version    = byte(0x00)
+	version    = common.PubKeyHashVersion
 	walletFile = "resources/wallet.dat"
 )
 
+// Wallet holds a key pair. PublicKey is compressed, except for wallets created
+// before compressed keys which keep the encoding their address derives from.
+//
+// WatchOnly wallets follow an address whose private key lives elsewhere, they
+// hold its public key, or only the Address when it was imported without one.
 type Wallet struct {
 	PrivateKey ecdsa.PrivateKey
 	PublicKey  []byte
+	WatchOnly  bool
+	Address    string
 }
 
 func NewWallet() (*Wallet, error) {
@@ -27,29 +34,37 @@ func NewWallet() (*Wallet, error) {
 		return nil, err
 	}
 
-	pubKey := append(privateKey.PublicKey.X.Bytes(), privateKey.PublicKey.Y.Bytes()...)
+	return &Wallet{PrivateKey: *privateKey, PublicKey: common.MarshalPubKey(&privateKey.PublicKey)}, nil
+}
+
+// newDerivedWallet derives the key of wallet index from a seed, at path
+// m/0'/0'/index' so that a leaked key exposes neither its parent nor its
+// siblings.
+func newDerivedWallet(seed []byte, index uint32) *Wallet {
+	key := newMasterKey(seed).derive(hardenedKeyStart, hardenedKeyStart, hardenedKeyStart+index)
+	privateKey := key.privateKey()
 
-	return &Wallet{PrivateKey: *privateKey, PublicKey: pubKey}, nil
+	return &Wallet{PrivateKey: *privateKey, PublicKey: common.MarshalPubKey(&privateKey.PublicKey)}
 }
 
 func (w Wallet) GetAddress() []byte {
-	pubHashKey := common.HashPubKey(w.PublicKey)
-
-	versionedPayload := append([]byte{version}, pubHashKey...)
-	checksum := common.Checksum(versionedPayload)
+	if w.PublicKey == nil {
+		return []byte(w.Address)
+	}
 
-	fullPayload := append(versionedPayload, checksum...)
-	address := common.Base58Encode(fullPayload)
+	pubHashKey := common.HashPubKey(w.PublicKey)
 
-	return address
+	return common.EncodeAddress(version, pubHashKey)
 }
 
 type _pkey struct {
-	D, X, Y *big.Int
+	D, X, Y   *big.Int
+	WatchOnly bool
+	Address   string
 }
 
 func (w *Wallet) GobEncode() ([]byte, error) {
-	privKey := &_pkey{D: w.PrivateKey.D, X: w.PrivateKey.PublicKey.X, Y: w.PrivateKey.PublicKey.Y}
+	privKey := &_pkey{D: w.PrivateKey.D, X: w.PrivateKey.PublicKey.X, Y: w.PrivateKey.PublicKey.Y, WatchOnly: w.WatchOnly, Address: w.Address}
 
 	var buffer bytes.Buffer
 	encoder := gob.NewEncoder(&buffer)
@@ -79,5 +94,9 @@ func (w *Wallet) GobDecode(data []byte) error {
 
 	w.PrivateKey = ecdsa.PrivateKey{D: privKey.D, PublicKey: ecdsa.PublicKey{X: privKey.X, Y: privKey.Y, Curve: elliptic.P256()}}
 	w.PublicKey = buf.Bytes()
+	if len(w.PublicKey) == 0 {
+		w.PublicKey = nil
+	}
+	w.WatchOnly, w.Address = privKey.WatchOnly, privKey.Address
 	return nil
 }
@@ -3,18 +3,43 @@ package wallet
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+var (
+	ErrNoSeed       = errors.New("wallet file has no seed, create one first")
+	ErrSeedExists   = errors.New("wallet file already has a seed")
+	ErrNoWallet     = errors.New("no local wallet for the address")
+	ErrWatchOnly    = errors.New("address is watch-only, its private key is not in the wallet")
+	ErrWalletExists = errors.New("address is already in the wallet")
 )
 
 type Wallets struct {
 	Wallets map[string]*Wallet
+	// Scripts holds the redeem scripts of script hash addresses.
+	Scripts map[string][]byte
+	// Seed derives the keys of new wallets, NextIndex being the index of
+	// the next one. Wallet files from before seeds have none, their keys
+	// are random.
+	Seed      []byte
+	NextIndex uint32
+	// Encryption is set when the private keys and seed are encrypted, the
+	// file then stores them only there. key opens it once unlocked.
+	Encryption *Encryption
+	key        []byte
 }
 
 func NewWallets() (*Wallets, error) {
 	wallets := Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
+	wallets.Scripts = make(map[string][]byte)
 	err := wallets.LoadFromFile()
 	if err != nil {
 		return nil, err
@@ -23,12 +48,19 @@ func NewWallets() (*Wallets, error) {
 	return &wallets, nil
 }
 
+// CreateWallet derives the next key from the seed and returns its address.
 func (ws *Wallets) CreateWallet() (string, error) {
-	wallet, err := NewWallet()
-	if err != nil {
+	if err := ws.EnsureUnlocked(); err != nil {
 		return "", err
 	}
 
+	if ws.Seed == nil {
+		return "", ErrNoSeed
+	}
+
+	wallet := newDerivedWallet(ws.Seed, ws.NextIndex)
+	ws.NextIndex++
+
 	address := string(wallet.GetAddress())
 
 	ws.Wallets[address] = wallet
@@ -36,8 +68,114 @@ func (ws *Wallets) CreateWallet() (string, error) {
 	return address, nil
 }
 
-func (ws *Wallets) GetWallet(address string) Wallet {
-	return *ws.Wallets[address]
+// NewSeed gives the wallet file a seed from a new mnemonic phrase of the given
+// number of words, which it returns for the user to write down.
+func (ws *Wallets) NewSeed(words int) (string, error) {
+	if err := ws.EnsureUnlocked(); err != nil {
+		return "", err
+	}
+
+	if ws.Seed != nil {
+		return "", ErrSeedExists
+	}
+
+	mnemonic, err := NewMnemonic(words)
+	if err != nil {
+		return "", err
+	}
+
+	ws.Seed = MnemonicSeed(mnemonic, "")
+	return mnemonic, nil
+}
+
+// Restore sets the seed of a mnemonic phrase and derives its first count
+// wallets again, returning their addresses.
+func (ws *Wallets) Restore(mnemonic string, count int) ([]string, error) {
+	if err := ws.EnsureUnlocked(); err != nil {
+		return nil, err
+	}
+
+	if ws.Seed != nil {
+		return nil, ErrSeedExists
+	}
+
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	ws.Seed = MnemonicSeed(mnemonic, "")
+
+	var addresses []string
+	for i := 0; i < count; i++ {
+		address, err := ws.CreateWallet()
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	return addresses, nil
+}
+
+// GetWallet returns the wallet of address, watch-only ones included.
+func (ws *Wallets) GetWallet(address string) (Wallet, error) {
+	w, ok := ws.Wallets[address]
+	if !ok {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrNoWallet, address)
+	}
+
+	return *w, nil
+}
+
+// SigningWallet returns the wallet of address if it can sign, that is it is
+// not watch-only, unlocking the wallet file if needed.
+func (ws *Wallets) SigningWallet(address string) (Wallet, error) {
+	w, err := ws.GetWallet(address)
+	if err != nil {
+		return Wallet{}, err
+	}
+
+	if w.WatchOnly {
+		return Wallet{}, fmt.Errorf("%w: %s", ErrWatchOnly, address)
+	}
+
+	// w was copied before unlocking filled in the private key.
+	if err = ws.EnsureUnlocked(); err != nil {
+		return Wallet{}, err
+	}
+
+	return ws.GetWallet(address)
+}
+
+// ImportAddress watches address, or the address of a public key given in hex,
+// without its private key. It returns the address watched.
+func (ws *Wallets) ImportAddress(addressOrPubKey string) (string, error) {
+	w := &Wallet{WatchOnly: true}
+
+	if common.ValidateAddress(addressOrPubKey) {
+		w.Address = addressOrPubKey
+	} else {
+		pubKey, err := hex.DecodeString(addressOrPubKey)
+		if err != nil {
+			return "", common.ErrBadAddress
+		}
+
+		key, err := common.ParsePubKey(pubKey)
+		if err != nil {
+			return "", err
+		}
+
+		w.PrivateKey.PublicKey = *key
+		w.PublicKey = pubKey
+	}
+
+	address := string(w.GetAddress())
+	if _, ok := ws.Wallets[address]; ok {
+		return "", fmt.Errorf("%w: %s", ErrWalletExists, address)
+	}
+
+	ws.Wallets[address] = w
+	return address, nil
 }
 
 func (ws *Wallets) LoadFromFile() error {
@@ -50,7 +188,7 @@ func (ws *Wallets) LoadFromFile() error {
 			}
 		}
 
-		file, err := os.OpenFile(walletFile, os.O_CREATE|os.O_WRONLY, 0644)
+		file, err := os.OpenFile(walletFile, os.O_CREATE|os.O_WRONLY, 0600)
 		if err != nil {
 			return err
 		}
@@ -73,11 +211,34 @@ func (ws *Wallets) LoadFromFile() error {
 	}
 
 	ws.Wallets = wallets.Wallets
+	if wallets.Scripts != nil {
+		ws.Scripts = wallets.Scripts
+	}
+	ws.Seed, ws.NextIndex = wallets.Seed, wallets.NextIndex
+	ws.Encryption = wallets.Encryption
 
-	return nil
+	return removeOldUnlockFile()
 }
 
+// SaveToFile writes the wallets, encrypting the private keys and seed when
+// the wallet is encrypted. A locked wallet keeps the ones already encrypted.
 func (ws Wallets) SaveToFile() error {
+	if ws.Encryption != nil {
+		if ws.key != nil {
+			if err := ws.seal(); err != nil {
+				return err
+			}
+		}
+
+		public := make(map[string]*Wallet, len(ws.Wallets))
+		for address, w := range ws.Wallets {
+			stripped := *w
+			stripped.PrivateKey.D = nil
+			public[address] = &stripped
+		}
+		ws.Wallets, ws.Seed = public, nil
+	}
+
 	var buffer bytes.Buffer
 
 	encoder := gob.NewEncoder(&buffer)
@@ -86,5 +247,16 @@ func (ws Wallets) SaveToFile() error {
 		log.Panic(err)
 	}
 
-	return os.WriteFile(walletFile, buffer.Bytes(), 0600)
+	return writeFileAtomic(walletFile, buffer.Bytes())
+}
+
+// writeFileAtomic replaces a file readable by its owner only, through a
+// temporary file so that it is never left half written.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
 }
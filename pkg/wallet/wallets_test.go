@@ -0,0 +1,134 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	common "github.com/blockmandu/pkg/commons"
+)
+
+func TestImportAddress(t *testing.T) {
+	other, err := NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherAddress := string(other.GetAddress())
+	otherPubKey := hex.EncodeToString(other.PublicKey)
+
+	tests := []struct {
+		name string
+		// imports are imported in turn, the last one gives err.
+		imports []string
+		// own and ownPubKey import the address or public key of the
+		// wallet's own key instead.
+		own       bool
+		ownPubKey bool
+		// pubKey tells the watched address keeps its public key.
+		pubKey bool
+		err    error
+	}{
+		{name: "address", imports: []string{otherAddress}},
+		{name: "public key", imports: []string{otherPubKey}, pubKey: true},
+		{name: "address twice", imports: []string{otherAddress, otherAddress}, err: ErrWalletExists},
+		{name: "public key of an imported address", imports: []string{otherAddress, otherPubKey}, err: ErrWalletExists},
+		{name: "own address", own: true, err: ErrWalletExists},
+		{name: "own public key", ownPubKey: true, err: ErrWalletExists},
+		{name: "neither", imports: []string{"not an address"}, err: common.ErrBadAddress},
+		{name: "bad public key", imports: []string{hex.EncodeToString([]byte("not a key"))}, err: common.ErrBadPubKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallets, address := newTestWallets(t)
+			imports := tt.imports
+			if tt.own {
+				imports = []string{address}
+			}
+			if tt.ownPubKey {
+				imports = []string{hex.EncodeToString(wallets.Wallets[address].PublicKey)}
+			}
+
+			var imported string
+			var err error
+			for i, addressOrPubKey := range imports {
+				imported, err = wallets.ImportAddress(addressOrPubKey)
+				if i < len(imports)-1 && err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ImportAddress = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if imported != otherAddress {
+				t.Errorf("imported %s, want %s", imported, otherAddress)
+			}
+			if err = wallets.SaveToFile(); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := NewWallets()
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := loaded.GetWallet(otherAddress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !w.WatchOnly || w.PrivateKey.D != nil {
+				t.Error("imported address is not watch-only")
+			}
+			if got := w.PublicKey != nil; got != tt.pubKey || (got && !bytes.Equal(w.PublicKey, other.PublicKey)) {
+				t.Errorf("public key %x kept, want %x: %v", w.PublicKey, other.PublicKey, tt.pubKey)
+			}
+			if string(w.GetAddress()) != otherAddress {
+				t.Errorf("watched address %s, want %s", w.GetAddress(), otherAddress)
+			}
+		})
+	}
+}
+
+func TestSigningWallet(t *testing.T) {
+	wallets, address := newTestWallets(t)
+
+	other, err := NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watched, err := wallets.ImportAddress(hex.EncodeToString(other.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := string(stranger.GetAddress())
+
+	tests := []struct {
+		name    string
+		address string
+		err     error
+	}{
+		{name: "own key", address: address},
+		{name: "watch-only", address: watched, err: ErrWatchOnly},
+		{name: "unknown address", address: unknown, err: ErrNoWallet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := wallets.SigningWallet(tt.address)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("SigningWallet = %v, want %v", err, tt.err)
+			}
+			if err == nil && w.PrivateKey.D == nil {
+				t.Error("signing wallet without a private key")
+			}
+		})
+	}
+}